@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	scryfall "github.com/BlueMonday/go-scryfall"
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	httpClientTimeout      = 30 * time.Second
+	bulkDataTimeout        = 5 * time.Minute
+	filePermission         = 0o600
+	directoryPermission    = 0o755
+	progressUpdateInterval = 500 * time.Millisecond
+	bytesPerMegabyte       = 1024 * 1024
+)
+
+// downloadCmd fetches the bulk data and the card images.
+//
+//nolint:exhaustivestruct // Not needed here
+var downloadCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Download the bulk data and the card images",
+	Long: `Downloads the Scryfall bulk data of --datatype into {dir}/{datatype}.json,
+then the highres and lowres images of its cards in --lang with --parallel workers,
+and writes {dir}/cards.json.`,
+	Args: cobra.NoArgs,
+	RunE: download,
+}
+
+func init() {
+	rootCmd.AddCommand(downloadCmd)
+}
+
+// progressReader wraps an io.Reader to display download progress.
+type progressReader struct {
+	reader    io.Reader
+	total     int64
+	current   int64
+	lastPrint time.Time
+}
+
+// Read implements io.Reader and prints progress periodically.
+//
+//nolint:wrapcheck // error passthrough required for io.Reader interface
+func (pr *progressReader) Read(p []byte) (int, error) {
+	bytesRead, err := pr.reader.Read(p)
+	pr.current += int64(bytesRead)
+
+	if time.Since(pr.lastPrint) > progressUpdateInterval || err == io.EOF {
+		percentage := float64(0)
+		if pr.total > 0 {
+			percentage = float64(pr.current) * 100 / float64(pr.total)
+		}
+
+		log.Printf("\rDownloaded: %.2f MB / %.2f MB (%.1f%%)",
+			float64(pr.current)/bytesPerMegabyte,
+			float64(pr.total)/bytesPerMegabyte,
+			percentage)
+
+		pr.lastPrint = time.Now()
+
+		if err == io.EOF {
+			log.Println()
+		}
+	}
+
+	return bytesRead, err
+}
+
+func download(command *cobra.Command, _ []string) error {
+	ctx := command.Context()
+	dir := viper.GetString(keyDir)
+
+	cards, err := getAllCards(ctx, dir, viper.GetString(keyDataType))
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout: httpClientTimeout,
+	}
+
+	workersCount := parallel()
+
+	log.Printf("Spawning %d workers...\n", workersCount)
+
+	var waitGroup sync.WaitGroup
+
+	jobIndex := make(chan int, workersCount)
+
+	for range workersCount {
+		waitGroup.Go(func() {
+			worker(ctx, client, dir, cards, jobIndex)
+		})
+	}
+
+	for index := range cards {
+		jobIndex <- index
+	}
+
+	close(jobIndex)
+	waitGroup.Wait()
+
+	return writeReport(dir, cards)
+}
+
+func downloadAndSave(ctx context.Context, client *http.Client, imageurl, filepath string) error {
+	_, err := url.ParseRequestURI(imageurl)
+	if err != nil {
+		return errors.Wrap(err, "invalid url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageurl, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "cannot create request")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "cannot download file")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "cannot read body")
+	}
+
+	if len(body) == 0 {
+		return errors.New("empty response body")
+	}
+
+	err = os.MkdirAll(path.Dir(filepath), directoryPermission)
+	if err != nil {
+		return errors.Wrap(err, "cannot create directory")
+	}
+
+	err = os.WriteFile(filepath, body, filePermission)
+	if err != nil {
+		return errors.Wrap(err, "cannot write file")
+	}
+
+	return nil
+}
+
+// getAllCards downloads the bulk data of the type and keeps a copy in dir for the report command.
+//
+//nolint:funlen // Function handles complex bulk data download with progress
+func getAllCards(ctx context.Context, dir, dataType string) ([]scryfall.Card, error) {
+	client, err := scryfall.NewClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create scryfall client")
+	}
+
+	lbd, err := client.ListBulkData(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get bulk data form scryfall")
+	}
+
+	cards := []scryfall.Card{}
+
+	bulkClient := &http.Client{
+		Timeout: bulkDataTimeout,
+	}
+
+	for index := range lbd {
+		if lbd[index].Type != dataType {
+			continue
+		}
+
+		log.Printf("Downloading bulk data for %s...\n", dataType)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, lbd[index].DownloadURI, http.NoBody)
+		if err != nil {
+			return nil, errors.Wrap(err, "cant create request")
+		}
+
+		resp, err := bulkClient.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "cant do request")
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+
+			return nil, errors.Errorf("status code error: %d %s", resp.StatusCode, resp.Status)
+		}
+
+		progress := &progressReader{
+			reader:    resp.Body,
+			total:     resp.ContentLength,
+			lastPrint: time.Now(),
+		}
+
+		body, err := io.ReadAll(progress)
+		if err != nil {
+			resp.Body.Close()
+
+			return nil, errors.Wrap(err, "cant read body")
+		}
+
+		resp.Body.Close()
+
+		err = json.Unmarshal(body, &cards)
+		if err != nil {
+			return nil, errors.Wrap(err, "cant unmarshal json")
+		}
+
+		err = os.MkdirAll(dir, directoryPermission)
+		if err != nil {
+			return nil, errors.Wrap(err, "cant create dataset directory")
+		}
+
+		err = os.WriteFile(bulkPath(dir, dataType), body, filePermission)
+		if err != nil {
+			return nil, errors.Wrap(err, "cant save bulk data")
+		}
+	}
+
+	return cards, nil
+}
+
+func worker(
+	ctx context.Context,
+	client *http.Client,
+	dir string,
+	cards []scryfall.Card,
+	indexChan <-chan int,
+) {
+	language := viper.GetString(keyLanguage)
+
+	for index := range indexChan {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		card := &cards[index]
+
+		// Filter by language if specified.
+		if language != "" && string(card.Lang) != language {
+			continue
+		}
+
+		log.Printf("%.2f%% %s", float64(index)*float64(100)/float64(len(cards)), cardKey(card))
+
+		if card.ImageStatus == nil ||
+			*card.ImageStatus != scryfall.ImageStatusHighres && *card.ImageStatus != scryfall.ImageStatusLowres {
+			continue
+		}
+
+		for _, image := range cardImages(dir, card) {
+			err := downloadAndSave(ctx, client, image.url, image.path)
+			if err != nil {
+				log.Printf("error downloading %s: %s", cardKey(card), err)
+			}
+		}
+	}
+}
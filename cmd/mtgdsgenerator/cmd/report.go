@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+
+	scryfall "github.com/BlueMonday/go-scryfall"
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportCmd rebuilds cards.json from the saved bulk data and the images on disk.
+//
+//nolint:exhaustivestruct // Not needed here
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Rebuild cards.json from the dataset on disk",
+	Long: `Reads {dir}/{datatype}.json saved by download and writes {dir}/cards.json
+with the cards in --lang whose images are in {dir}/images, without network access.`,
+	Args: cobra.NoArgs,
+	RunE: report,
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+}
+
+// cardImage is an image of a card or of one of its faces.
+type cardImage struct {
+	url  string
+	path string
+}
+
+func report(_ *cobra.Command, _ []string) error {
+	dir := viper.GetString(keyDir)
+
+	cards, err := loadBulk(bulkPath(dir, viper.GetString(keyDataType)))
+	if err != nil {
+		return err
+	}
+
+	return writeReport(dir, cards)
+}
+
+func bulkPath(dir, dataType string) string {
+	return dir + "/" + dataType + ".json"
+}
+
+func cardsPath(dir string) string {
+	return dir + "/cards.json"
+}
+
+func imagesPath(dir string) string {
+	return dir + "/images"
+}
+
+// cardKey names a card in cards.json.
+func cardKey(card *scryfall.Card) string {
+	return card.Name + " " + card.Set + " " + string(card.Lang) + " " + card.CollectorNumber
+}
+
+// cardImages lists the images of a card, a double-faced card has an image per face.
+func cardImages(dir string, card *scryfall.Card) []cardImage {
+	prefix := imagesPath(dir) + "/" + card.Set + "/" + string(card.Lang) + "/" + card.ID
+
+	if card.ImageURIs != nil {
+		return []cardImage{{url: card.ImageURIs.Normal, path: prefix + ".jpg"}}
+	}
+
+	images := make([]cardImage, 0, len(card.CardFaces))
+
+	for faceIndex := range card.CardFaces {
+		images = append(images, cardImage{
+			url:  card.CardFaces[faceIndex].ImageURIs.Normal,
+			path: prefix + strconv.Itoa(faceIndex) + ".jpg",
+		})
+	}
+
+	return images
+}
+
+func loadBulk(path string) ([]scryfall.Card, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cant read bulk data, run download first")
+	}
+
+	cards := []scryfall.Card{}
+
+	err = json.Unmarshal(data, &cards)
+	if err != nil {
+		return nil, errors.Wrap(err, "cant unmarshal bulk data")
+	}
+
+	return cards, nil
+}
+
+// writeReport maps the cards in the language to their images on disk in cards.json.
+func writeReport(dir string, cards []scryfall.Card) error {
+	log.Println("Generating report...")
+
+	language := viper.GetString(keyLanguage)
+	resultMap := make(map[string][]string)
+
+	for index := range cards {
+		card := &cards[index]
+
+		if language != "" && string(card.Lang) != language {
+			continue
+		}
+
+		for _, image := range cardImages(dir, card) {
+			if _, err := os.Stat(image.path); err == nil {
+				resultMap[cardKey(card)] = append(resultMap[cardKey(card)], image.path)
+			}
+		}
+	}
+
+	jsondata, err := json.Marshal(resultMap)
+	if err != nil {
+		return errors.Wrap(err, "cant marshal json")
+	}
+
+	err = os.WriteFile(cardsPath(dir), jsondata, filePermission)
+	if err != nil {
+		return errors.Wrap(err, "cant write file")
+	}
+
+	log.Printf("%d cards in %s\n", len(resultMap), cardsPath(dir))
+
+	return nil
+}
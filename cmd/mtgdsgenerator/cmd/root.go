@@ -13,18 +13,30 @@ import (
 	"github.com/spf13/viper"
 )
 
-var (
-	DataType string
-	Language string
-	cfgFile  string
-	Parallel uint
+const (
+	// envPrefix keeps the settings away from unrelated variables such as LANG.
+	envPrefix = "MTGDS"
+
+	keyDataType = "datatype"
+	keyLanguage = "lang"
+	keyParallel = "parallel"
+	keyDir      = "dir"
 )
 
+var cfgFile string
+
 // rootCmd represents the base command when called without any subcommands.
 //
 //nolint:exhaustivestruct // Not needed here
 var rootCmd = &cobra.Command{
-	Use: "mtgdsgenerator",
+	Use:   "mtgdsgenerator",
+	Short: "Build a Magic: The Gathering image dataset from Scryfall",
+	Long: `Downloads card images from Scryfall into {dir}/images/{set}/{lang}/ and keeps
+{dir}/cards.json mapping every card to its images.
+
+Flags may also be set in the config file or as MTGDS_* environment variables,
+MTGDS_LANG=en for example.`,
+	Args: cobra.NoArgs,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -38,16 +50,18 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mtgdsgenerator.yaml)")
-	rootCmd.PersistentFlags().StringVar(&DataType, "datatype", "all_cards",
-		"type of cards archive (all_cards, oracle_cards, etc)")
-	rootCmd.PersistentFlags().StringVar(&Language, "lang", "",
+	flags := rootCmd.PersistentFlags()
+
+	flags.StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mtgdsgenerator.yaml)")
+	flags.String(keyDataType, "all_cards", "type of cards archive (all_cards, oracle_cards, etc)")
+	flags.String(keyLanguage, "",
 		"filter cards by language code (e.g., en, ru, ja). Empty string means all languages")
-	rootCmd.PersistentFlags().UintVar(&Parallel, "parallel", 10, "number of parallel downloaders")
+	flags.Uint(keyParallel, 10, "number of parallel workers") //nolint:mnd // default
+	flags.String(keyDir, ".", "dataset directory")
 
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	for _, key := range []string{keyDataType, keyLanguage, keyParallel, keyDir} {
+		cobra.CheckErr(viper.BindPFlag(key, flags.Lookup(key)))
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -66,6 +80,7 @@ func initConfig() {
 		viper.SetConfigName(".mtgdsgenerator")
 	}
 
+	viper.SetEnvPrefix(envPrefix)
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
@@ -74,3 +89,8 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// parallel returns the number of workers, at least one.
+func parallel() int {
+	return max(int(viper.GetUint(keyParallel)), 1) //nolint:gosec // a flag, never near the limits
+}
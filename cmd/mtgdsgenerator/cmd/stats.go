@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statsCmd summarizes a dataset.
+//
+//nolint:exhaustivestruct // Not needed here
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the dataset on disk",
+	Long: `Counts the cards in {dir}/cards.json and the images in {dir}/images
+with their size, per language and in total.`,
+	Args: cobra.NoArgs,
+	RunE: stats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
+
+// datasetStats counts the images of a dataset.
+type datasetStats struct {
+	images    int
+	bytes     int64
+	sets      map[string]struct{}
+	languages map[string]*languageStats
+}
+
+type languageStats struct {
+	images int
+	bytes  int64
+	sets   map[string]struct{}
+}
+
+func stats(_ *cobra.Command, _ []string) error {
+	dir := viper.GetString(keyDir)
+
+	summary, err := collectStats(imagesPath(dir))
+	if err != nil {
+		return err
+	}
+
+	cards := -1
+
+	result, err := loadReport(cardsPath(dir))
+	if err == nil {
+		cards = len(result)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Println(err)
+	}
+
+	return summary.write(os.Stdout, cards)
+}
+
+// collectStats walks root laid out as {set}/{lang}/{id}.jpg.
+func collectStats(root string) (*datasetStats, error) {
+	summary := &datasetStats{
+		sets:      make(map[string]struct{}),
+		languages: make(map[string]*languageStats),
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || !strings.HasSuffix(path, ".jpg") {
+			return nil
+		}
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(filepath.ToSlash(relative), "/")
+		if len(parts) != 3 { //nolint:mnd // set, language and file
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		set, language := parts[0], parts[1]
+
+		if summary.languages[language] == nil {
+			summary.languages[language] = &languageStats{sets: make(map[string]struct{})}
+		}
+
+		summary.images++
+		summary.bytes += info.Size()
+		summary.sets[set] = struct{}{}
+
+		perLanguage := summary.languages[language]
+		perLanguage.images++
+		perLanguage.bytes += info.Size()
+		perLanguage.sets[set] = struct{}{}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cant walk images")
+	}
+
+	return summary, nil
+}
+
+// write prints the summary, cards is negative without cards.json.
+func (summary *datasetStats) write(out io.Writer, cards int) error {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight) //nolint:mnd // padding
+
+	if cards >= 0 {
+		fmt.Fprintf(table, "cards\t%d\t\n", cards)
+	}
+
+	fmt.Fprintf(table, "images\t%d\t\n", summary.images)
+	fmt.Fprintf(table, "sets\t%d\t\n", len(summary.sets))
+	fmt.Fprintf(table, "size, MB\t%.1f\t\n\n", float64(summary.bytes)/bytesPerMegabyte)
+	fmt.Fprintf(table, "lang\timages\tsets\tsize, MB\t\n")
+
+	for _, language := range slices.Sorted(maps.Keys(summary.languages)) {
+		perLanguage := summary.languages[language]
+
+		fmt.Fprintf(table, "%s\t%d\t%d\t%.1f\t\n",
+			language, perLanguage.images, len(perLanguage.sets), float64(perLanguage.bytes)/bytesPerMegabyte)
+	}
+
+	err := table.Flush()
+	if err != nil {
+		return errors.Wrap(err, "cant write stats")
+	}
+
+	return nil
+}
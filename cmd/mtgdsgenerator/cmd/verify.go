@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"encoding/json"
+	"image/jpeg"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var verifyRemove bool
+
+// verifyCmd checks that the images decode and that cards.json points at existing files.
+//
+//nolint:exhaustivestruct // Not needed here
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the downloaded images",
+	Long: `Decodes every image in {dir}/images with --parallel workers and checks that
+every path in {dir}/cards.json exists. Broken images are listed and, with --remove,
+deleted so that the next download fetches them again.
+
+Exits with an error when anything is broken or missing.`,
+	Args:         cobra.NoArgs,
+	RunE:         verify,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().BoolVar(&verifyRemove, "remove", false, "delete broken images")
+}
+
+func verify(_ *cobra.Command, _ []string) error {
+	dir := viper.GetString(keyDir)
+
+	checked, broken, err := verifyImages(imagesPath(dir), parallel(), verifyRemove)
+	if err != nil {
+		return err
+	}
+
+	missing, err := verifyReport(cardsPath(dir))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d images checked, %d broken, %d missing from cards.json\n", checked, broken, missing)
+
+	if broken > 0 || missing > 0 {
+		return errors.Newf("%d broken and %d missing images", broken, missing)
+	}
+
+	return nil
+}
+
+// verifyImages decodes the JPEG files under root and returns how many were checked and broken.
+func verifyImages(root string, workersCount int, remove bool) (int64, int64, error) {
+	var (
+		waitGroup       sync.WaitGroup
+		checked, broken atomic.Int64
+	)
+
+	paths := make(chan string, workersCount)
+
+	for range workersCount {
+		waitGroup.Go(func() {
+			for path := range paths {
+				checked.Add(1)
+
+				err := decodeImage(path)
+				if err == nil {
+					continue
+				}
+
+				broken.Add(1)
+				log.Printf("broken %s: %s", path, err)
+
+				if remove {
+					err = os.Remove(path)
+					if err != nil {
+						log.Printf("cant remove %s: %s", path, err)
+					}
+				}
+			}
+		})
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() && strings.HasSuffix(path, ".jpg") {
+			paths <- path
+		}
+
+		return nil
+	})
+
+	close(paths)
+	waitGroup.Wait()
+
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "cant walk images")
+	}
+
+	return checked.Load(), broken.Load(), nil
+}
+
+func decodeImage(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "cant open image")
+	}
+	defer file.Close()
+
+	_, err = jpeg.Decode(file)
+	if err != nil {
+		return errors.Wrap(err, "cant decode image")
+	}
+
+	return nil
+}
+
+// verifyReport returns how many paths of cards.json do not exist, a missing cards.json is not checked.
+func verifyReport(path string) (int, error) {
+	result, err := loadReport(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("%s not found, skipping\n", path)
+
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	missing := 0
+
+	for key, paths := range result {
+		for _, imagePath := range paths {
+			if _, err := os.Stat(imagePath); err != nil {
+				missing++
+
+				log.Printf("missing %s of %s", imagePath, key)
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+func loadReport(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cant read cards.json")
+	}
+
+	result := make(map[string][]string)
+
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, "cant unmarshal cards.json")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	vt "github.com/lexfrei/tools/internal/pkg/vk2tg"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatJSONL = "jsonl"
+	formatHTML  = "html"
+)
+
+var (
+	exportSince  string
+	exportFormat string
+	exportOutput string
+)
+
+// exportCmd dumps the archive of forwarded posts.
+//
+//nolint:exhaustivestruct // Not needed here
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the archive of forwarded posts to JSONL or static HTML",
+	Long: fmt.Sprintf(`Reads the archive of forwarded posts from Redis (V2T_REDIS_URL or V2T_REDIS_ADDR)
+and writes the posts forwarded since the given time with their source, text,
+media URLs, delivery targets and timestamps.
+
+jsonl writes a post per line to --output, stdout by default.
+html writes an index and a browsable page per day with photo thumbnails
+to the --output directory, "archive" by default.
+
+--since takes a date (2006-01-02), an RFC 3339 time or a duration back from now (72h).
+Dates and days of the html pages are in %s.
+
+The archive only keeps the last %d forwarded posts, so an export since an earlier time
+starts with the oldest post still kept.`, vt.ExportZone(), vt.ArchiveSize),
+	Args: cobra.NoArgs,
+	RunE: export,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportSince, "since", "", "export posts forwarded since this date, time or duration ago")
+	exportCmd.Flags().StringVar(&exportFormat, "format", formatJSONL, "output format: jsonl or html")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "", "output file for jsonl or directory for html")
+
+	cobra.CheckErr(exportCmd.MarkFlagRequired("since"))
+}
+
+func export(_ *cobra.Command, _ []string) error {
+	since, err := parseSince(exportSince)
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, serviceName+": ", log.Ldate|log.Ltime|log.Lshortfile)
+	vtClient := vt.NewVTClient("", "", 0, period).WithLogger(logger)
+
+	connected, err := withStorage(vtClient)
+	if err != nil {
+		return err
+	}
+
+	if !connected {
+		return errors.New("the archive is kept in Redis, set V2T_REDIS_URL or V2T_REDIS_ADDR")
+	}
+
+	var count int
+
+	switch exportFormat {
+	case formatJSONL:
+		count, err = exportJSONL(vtClient, since)
+	case formatHTML:
+		if exportOutput == "" {
+			exportOutput = "archive"
+		}
+
+		count, err = vtClient.ExportHTML(exportOutput, since)
+	default:
+		return errors.Newf("unknown format %q, use jsonl or html", exportFormat)
+	}
+
+	if err != nil {
+		return err //nolint:wrapcheck // already descriptive
+	}
+
+	logger.Printf("Exported %d posts forwarded since %s", count, since.Format(time.RFC3339))
+
+	return nil
+}
+
+func exportJSONL(vtClient *vt.VTClinent, since time.Time) (int, error) {
+	if exportOutput == "" {
+		return vtClient.ExportJSONL(os.Stdout, since) //nolint:wrapcheck // already descriptive
+	}
+
+	file, err := os.Create(exportOutput)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't create output file")
+	}
+
+	count, err := vtClient.ExportJSONL(file, since)
+	if err != nil {
+		_ = file.Close()
+
+		return 0, err //nolint:wrapcheck // already descriptive
+	}
+
+	// Writes may only fail on close, when the data is flushed to disk.
+	err = file.Close()
+	if err != nil {
+		return 0, errors.Wrap(err, "can't write output file")
+	}
+
+	return count, nil
+}
+
+// parseSince reads a date, an RFC 3339 time or a duration back from now.
+// Dates start in the timezone the export groups days in.
+func parseSince(value string) (time.Time, error) {
+	if since, err := time.ParseInLocation(time.DateOnly, value, vt.ExportZone()); err == nil {
+		return since, nil
+	}
+
+	if since, err := time.Parse(time.RFC3339, value); err == nil {
+		return since, nil
+	}
+
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+
+	return time.Time{}, errors.Newf("invalid --since %q, use 2006-01-02, an RFC 3339 time or a duration like 72h", value)
+}
@@ -0,0 +1,135 @@
+// Package cmd provides command line interface for vk2tg
+package cmd
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	vt "github.com/lexfrei/tools/internal/pkg/vk2tg"
+	"github.com/spf13/cobra"
+)
+
+const (
+	period      = 10 * time.Second
+	serviceName = "VK2TG"
+	httpListen  = ":8420"
+)
+
+var dryRun bool
+
+// rootCmd runs the bot.
+//
+//nolint:exhaustivestruct // Not needed here
+var rootCmd = &cobra.Command{
+	Use:   "vk2tg",
+	Short: "Forward VK wall posts to Telegram",
+	Args:  cobra.NoArgs,
+	Run:   run,
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	if rootCmd.Execute() != nil {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false,
+		"print filter decisions and rendered messages to stdout instead of sending them, keeps state in memory")
+}
+
+func run(_ *cobra.Command, _ []string) {
+	logger := log.New(os.Stdout, serviceName+": ", log.Ldate|log.Ltime|log.Lshortfile)
+
+	// Stdout is left for the dry run reports.
+	if dryRun {
+		logger.SetOutput(os.Stderr)
+	}
+
+	user, err := strconv.ParseInt(os.Getenv("V2T_TG_USER"), 10, 64)
+	if err != nil {
+		logger.Fatalf("Invalid TG user ID: %s\n", os.Getenv("V2T_TG_USER"))
+	}
+
+	vtClient := vt.NewVTClient(
+		os.Getenv("V2T_TG_TOKEN"),
+		os.Getenv("V2T_VK_TOKEN"),
+		user,
+		period,
+	).WithLogger(
+		logger,
+	)
+
+	if os.Getenv("V2T_CONFIG") != "" {
+		pipeline, err := vt.LoadPipeline(os.Getenv("V2T_CONFIG"))
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		vtClient.WithPipeline(pipeline)
+	}
+
+	if dryRun {
+		// Shared state would move the production replicas past the posts seen here.
+		logger.Println("Dry run: Redis, HTTP and webhook settings are ignored")
+
+		err = vtClient.WithDryRun(os.Stdout).Start()
+		if err != nil {
+			logger.Fatalln(err)
+		}
+
+		vtClient.Wait()
+
+		return
+	}
+
+	_, err = withStorage(vtClient)
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	listen := httpListen
+	if os.Getenv("V2T_HTTP_LISTEN") != "" {
+		listen = os.Getenv("V2T_HTTP_LISTEN")
+	}
+
+	vtClient.WithHTTP(listen)
+
+	if os.Getenv("V2T_WEBHOOK_URL") != "" {
+		vtClient.WithWebhook(
+			os.Getenv("V2T_WEBHOOK_URL"),
+			os.Getenv("V2T_WEBHOOK_SECRET"),
+		)
+	}
+
+	err = vtClient.Start()
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	vtClient.Wait()
+}
+
+// withStorage connects the client to Redis when it is configured and reports whether it is.
+func withStorage(vtClient *vt.VTClinent) (bool, error) {
+	var err error
+
+	switch {
+	case os.Getenv("V2T_REDIS_URL") != "":
+		_, err = vtClient.WithRedisURL(serviceName, os.Getenv("V2T_REDIS_URL"))
+	case os.Getenv("V2T_REDIS_ADDR") != "":
+		_, err = vtClient.WithRedis(
+			serviceName,
+			os.Getenv("V2T_REDIS_ADDR"),
+			os.Getenv("V2T_REDIS_PASS"),
+		)
+	default:
+		return false, nil
+	}
+
+	return err == nil, err //nolint:wrapcheck // already descriptive
+}
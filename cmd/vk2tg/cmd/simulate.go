@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	vt "github.com/lexfrei/tools/internal/pkg/vk2tg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fixtures     string
+	pipelineFile string
+)
+
+// simulateCmd pushes recorded posts through the pipeline without Telegram.
+//
+//nolint:exhaustivestruct // Not needed here
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Print what the pipeline would do with recorded wall posts",
+	Long: `Reads wall posts from a JSON file, either an array of posts or a wall.get response,
+runs them through filters, routes and templates and prints the filter decisions
+and the Bot API calls that would have been made. Nothing is sent.
+
+V2T_VK_TOKEN is optional and only used to resolve author names.`,
+	Args: cobra.NoArgs,
+	RunE: simulate,
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+
+	simulateCmd.Flags().StringVar(&fixtures, "fixtures", "", "JSON file with recorded wall posts")
+	simulateCmd.Flags().StringVar(&pipelineFile, "config", "", "pipeline config (default is $V2T_CONFIG)")
+
+	cobra.CheckErr(simulateCmd.MarkFlagRequired("fixtures"))
+}
+
+func simulate(_ *cobra.Command, _ []string) error {
+	logger := log.New(os.Stderr, serviceName+": ", log.Ldate|log.Ltime|log.Lshortfile)
+
+	// Routes without a chat fall back to the user, which is just a number in the report.
+	user, _ := strconv.ParseInt(os.Getenv("V2T_TG_USER"), 10, 64)
+
+	vtClient := vt.NewVTClient("", os.Getenv("V2T_VK_TOKEN"), user, period).
+		WithLogger(logger).
+		WithDryRun(os.Stdout)
+
+	if pipelineFile == "" {
+		pipelineFile = os.Getenv("V2T_CONFIG")
+	}
+
+	if pipelineFile != "" {
+		pipeline, err := vt.LoadPipeline(pipelineFile)
+		if err != nil {
+			return err //nolint:wrapcheck // already descriptive
+		}
+
+		vtClient.WithPipeline(pipeline)
+	}
+
+	return vtClient.Simulate(fixtures) //nolint:wrapcheck // already descriptive
+}
@@ -0,0 +1,313 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// Callback endpoints of the message action buttons.
+// Their data is the "<owner>_<post>" reference of the post, so it stays within the 64 bytes Telegram allows.
+const (
+	handledUnique = "act_handled"
+	lessUnique    = "act_less"
+)
+
+// handledRetention is how long it is remembered who handled a message.
+const handledRetention = 90 * 24 * time.Hour
+
+var hashtagPattern = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+
+// Actions adds callback buttons to the messages of a route.
+type Actions struct {
+	// Handled lets chat members mark a message as resolved.
+	Handled bool `yaml:"handled"`
+	// LessLikeThis lets a subscriber mute the hashtags of a post.
+	// Muted hashtags are skipped in routes to the private chat of the subscriber.
+	LessLikeThis bool `yaml:"lessLikeThis"`
+}
+
+// handledMessage records who marked a message as handled, as persisted in storage.
+type handledMessage struct {
+	UserID int64     `json:"userId"`
+	Name   string    `json:"name"`
+	At     time.Time `json:"at"`
+}
+
+// buttons returns the action buttons for a message of the post.
+func (actions *Actions) buttons(post *vkObject.WallWallpost, lang string) []tb.InlineButton {
+	ref := strconv.Itoa(post.OwnerID) + "_" + strconv.Itoa(post.ID)
+
+	var row []tb.InlineButton
+
+	if actions.Handled {
+		row = append(row, tb.InlineButton{Unique: handledUnique, Text: translate(lang, "actions.handled"), Data: ref})
+	}
+
+	if actions.LessLikeThis {
+		row = append(row, tb.InlineButton{Unique: lessUnique, Text: translate(lang, "actions.less"), Data: ref})
+	}
+
+	return row
+}
+
+func handledKey(chatID int64, messageID int) string {
+	return "handled:" + strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(messageID)
+}
+
+func mutesKey(userID int64) string {
+	return "mutes:" + strconv.FormatInt(userID, 10)
+}
+
+// withoutButton returns the keyboard without buttons of the callback endpoint.
+func withoutButton(markup *tb.ReplyMarkup, unique string) *tb.ReplyMarkup {
+	keyboard := &tb.ReplyMarkup{}
+	if markup == nil {
+		return keyboard
+	}
+
+	prefix := (&tb.InlineButton{Unique: unique}).CallbackUnique()
+
+	for _, row := range markup.InlineKeyboard {
+		row = slices.DeleteFunc(slices.Clone(row), func(button tb.InlineButton) bool {
+			return strings.HasPrefix(button.Data, prefix)
+		})
+
+		if len(row) > 0 {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+		}
+	}
+
+	return keyboard
+}
+
+// markHandled records who handled the message, edits it to say so and removes the button.
+func (vtCli *VTClinent) markHandled(tbContext tb.Context) error {
+	msg := tbContext.Message()
+	if msg == nil {
+		return respond(tbContext, vtCli.tr(tbContext, "actions.tooOld"))
+	}
+
+	handled := &handledMessage{
+		UserID: tbContext.Sender().ID,
+		Name:   displayName(tbContext.Sender()),
+		At:     time.Now(),
+	}
+
+	earlier, err := vtCli.claimHandled(msg, handled)
+	if err != nil {
+		vtCli.logger.Printf("Can't save handled message %d: %s", msg.ID, err)
+
+		return respond(tbContext, vtCli.tr(tbContext, "actions.saveError"))
+	}
+
+	if earlier != nil {
+		return respond(tbContext, vtCli.tr(tbContext, "actions.alreadyHandled", earlier.Name))
+	}
+
+	vtCli.logger.Printf("Post %s: Handled by %d in %d", tbContext.Callback().Data, handled.UserID, msg.Chat.ID)
+
+	// Entities keep the formatting of the message, the mark is appended after them.
+	_, err = vtCli.tgClient.Edit(
+		msg,
+		msg.Text+"\n\n"+translate(vtCli.chatLanguage(msg.Chat.ID), "actions.handledBy", handled.Name, handled.At.In(zone).Format("15:04")),
+		&tb.SendOptions{Entities: msg.Entities, ReplyMarkup: withoutButton(msg.ReplyMarkup, handledUnique)},
+	)
+	if err != nil {
+		vtCli.logger.Printf("Can't update handled message %d: %s", msg.ID, err)
+	}
+
+	return respond(tbContext, vtCli.tr(tbContext, "actions.marked"))
+}
+
+// claimHandled saves who handled the message unless someone did it earlier, then it returns them.
+func (vtCli *VTClinent) claimHandled(msg *tb.Message, handled *handledMessage) (*handledMessage, error) {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	data, err := vtCli.storage.Get(handledKey(msg.Chat.ID, msg.ID))
+	if err == nil {
+		earlier := new(handledMessage)
+		if json.Unmarshal(data, earlier) == nil {
+			return earlier, nil
+		}
+	}
+
+	data, err = json.Marshal(handled)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encode handled message")
+	}
+
+	return nil, vtCli.storage.Set(handledKey(msg.Chat.ID, msg.ID), data, handledRetention)
+}
+
+// lessLikeThis toggles the hashtags of the post in the negative keywords of the user.
+func (vtCli *VTClinent) lessLikeThis(tbContext tb.Context) error {
+	text := ""
+	if msg := tbContext.Message(); msg != nil {
+		text = msg.Text
+	}
+
+	if post, err := vtCli.findArchived(tbContext.Callback().Data); err == nil {
+		text = post.Text
+	}
+
+	keywords := vtCli.postKeywords(text)
+	if len(keywords) == 0 {
+		return respond(tbContext, vtCli.tr(tbContext, "actions.noTags"))
+	}
+
+	muted, failure := vtCli.toggleMutes(tbContext.Sender().ID, keywords)
+	if failure != "" {
+		return respond(tbContext, vtCli.tr(tbContext, failure))
+	}
+
+	reply := "actions.unmuted"
+	if muted {
+		reply = "actions.muted"
+	}
+
+	return respond(tbContext, vtCli.tr(tbContext, reply, strings.Join(keywords, " ")))
+}
+
+// toggleMutes mutes the keywords for the user, or unmutes them when all are muted already,
+// and reports whether they are muted now or the catalog key of the failure.
+func (vtCli *VTClinent) toggleMutes(userID int64, keywords []string) (bool, string) {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	mutes, err := vtCli.loadMutes(userID)
+	if err != nil {
+		vtCli.logger.Printf("Can't load mutes of %d: %s", userID, err)
+
+		return false, "actions.loadMutesError"
+	}
+
+	// Pressing again on a post whose hashtags are all muted unmutes them.
+	muted := slices.ContainsFunc(keywords, func(keyword string) bool { return !slices.Contains(mutes, keyword) })
+	if muted {
+		for _, keyword := range keywords {
+			if !slices.Contains(mutes, keyword) {
+				mutes = append(mutes, keyword)
+			}
+		}
+	} else {
+		mutes = slices.DeleteFunc(mutes, func(keyword string) bool { return slices.Contains(keywords, keyword) })
+	}
+
+	err = vtCli.saveMutes(userID, mutes)
+	if err != nil {
+		vtCli.logger.Printf("Can't save mutes of %d: %s", userID, err)
+
+		return false, "actions.saveMutesError"
+	}
+
+	vtCli.logger.Printf("Negative keywords of %d: %s", userID, strings.Join(mutes, " "))
+
+	return muted, ""
+}
+
+// findArchived looks up an archived post by its "<owner>_<post>" reference.
+func (vtCli *VTClinent) findArchived(ref string) (*archivedPost, error) {
+	ownerID, postID, err := parsePostRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if post.PostID == postID && post.OwnerID == ownerID {
+			return post, nil
+		}
+	}
+
+	return nil, errNotFound
+}
+
+// postKeywords returns the lowercased hashtags of the text,
+// except route filters, which would mute whole routes.
+func (vtCli *VTClinent) postKeywords(text string) []string {
+	var keywords []string
+
+	for _, tag := range hashtagPattern.FindAllString(text, -1) {
+		tag = strings.ToLower(tag)
+
+		if slices.Contains(keywords, tag) || slices.ContainsFunc(vtCli.routes, func(route *Route) bool {
+			return strings.EqualFold(route.Filter, tag)
+		}) {
+			continue
+		}
+
+		keywords = append(keywords, tag)
+	}
+
+	return keywords
+}
+
+func (vtCli *VTClinent) loadMutes(userID int64) ([]string, error) {
+	data, err := vtCli.storage.Get(mutesKey(userID))
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var mutes []string
+
+	err = json.Unmarshal(data, &mutes)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode mutes")
+	}
+
+	return mutes, nil
+}
+
+func (vtCli *VTClinent) saveMutes(userID int64, mutes []string) error {
+	if len(mutes) == 0 {
+		return vtCli.storage.Delete(mutesKey(userID))
+	}
+
+	data, err := json.Marshal(mutes)
+	if err != nil {
+		return errors.Wrap(err, "can't encode mutes")
+	}
+
+	return vtCli.storage.Set(mutesKey(userID), data, 0)
+}
+
+// mutedKeyword returns the negative keyword of the subscriber found in the post, if any.
+// Only private chats, whose IDs are user IDs, have a subscriber.
+func (vtCli *VTClinent) mutedKeyword(chatID int64, post *vkObject.WallWallpost) string {
+	if chatID <= 0 {
+		return ""
+	}
+
+	mutes, err := vtCli.loadMutes(chatID)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't load mutes of %d: %s", post.ID, chatID, err)
+
+		return ""
+	}
+
+	// Mutes are whole hashtags, so #cat doesn't mute #catering.
+	for _, tag := range hashtagPattern.FindAllString(post.Text, -1) {
+		if tag = strings.ToLower(tag); slices.Contains(mutes, tag) {
+			return tag
+		}
+	}
+
+	return ""
+}
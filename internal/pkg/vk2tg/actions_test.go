@@ -0,0 +1,116 @@
+package vk2tg
+
+import (
+	"slices"
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestMutedKeyword tests that negative keywords skip posts in the private chat of the subscriber only.
+func TestMutedKeyword(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.routes = []*Route{{Name: "search", Filter: "#поиск"}}
+
+	keywords := vtCli.postKeywords("#Поиск #Продам диван #продам #срочно")
+	if !slices.Equal(keywords, []string{"#продам", "#срочно"}) {
+		t.Fatalf("unexpected keywords %v", keywords)
+	}
+
+	err := vtCli.saveMutes(42, keywords[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		chatID  int64
+		text    string
+		keyword string
+	}{
+		{"muted", 42, "#ПРОДАМ велосипед", "#продам"},
+		{"other tags", 42, "#поиск кот", ""},
+		{"longer tag", 42, "#продамгараж недорого", ""},
+		{"plain word", 42, "продам велосипед", ""},
+		{"other user", 43, "#продам велосипед", ""},
+		{"group chat", -42, "#продам велосипед", ""},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			keyword := vtCli.mutedKeyword(testCase.chatID, &vkObject.WallWallpost{Text: testCase.text})
+			if keyword != testCase.keyword {
+				t.Errorf("expected %q, got %q", testCase.keyword, keyword)
+			}
+		})
+	}
+}
+
+// TestWithoutButton tests that only the buttons of the handled endpoint are removed from a sent keyboard.
+func TestWithoutButton(t *testing.T) {
+	row := (&Actions{Handled: true, LessLikeThis: true}).buttons(&vkObject.WallWallpost{ID: 2, OwnerID: -1}, langEN)
+
+	// Sent keyboards come back with the endpoint in the callback data.
+	for index := range row {
+		row[index].Data = row[index].CallbackUnique() + "|" + row[index].Data
+		row[index].Unique = ""
+	}
+
+	markup := &tb.ReplyMarkup{InlineKeyboard: [][]tb.InlineButton{{{Text: "🌎", URL: "https://vk.com/wall-1_2"}}, row}}
+
+	keyboard := withoutButton(markup, handledUnique)
+	if len(keyboard.InlineKeyboard) != 2 || len(keyboard.InlineKeyboard[1]) != 1 || keyboard.InlineKeyboard[1][0].Text != "🔕 Less like this" {
+		t.Errorf("unexpected keyboard %+v", keyboard.InlineKeyboard)
+	}
+}
+
+// TestClaimHandled tests that a message is only marked as handled by the first one to press the button.
+func TestClaimHandled(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+	msg := &tb.Message{ID: 7, Chat: &tb.Chat{ID: -100}}
+
+	earlier, err := vtCli.claimHandled(msg, &handledMessage{UserID: 1, Name: "Alice"})
+	if err != nil || earlier != nil {
+		t.Fatalf("expected the message to be claimed, got %+v, %v", earlier, err)
+	}
+
+	earlier, err = vtCli.claimHandled(msg, &handledMessage{UserID: 2, Name: "Bob"})
+	if err != nil || earlier == nil || earlier.Name != "Alice" {
+		t.Errorf("expected the message to stay claimed by Alice, got %+v, %v", earlier, err)
+	}
+}
+
+// TestToggleMutes tests that keywords are muted unless all of them are muted already.
+func TestToggleMutes(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+
+	tests := []struct {
+		name     string
+		keywords []string
+		muted    bool
+		mutes    []string
+	}{
+		{"mute", []string{"#продам"}, true, []string{"#продам"}},
+		{"mute more", []string{"#продам", "#срочно"}, true, []string{"#продам", "#срочно"}},
+		{"unmute", []string{"#срочно"}, false, []string{"#продам"}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			muted, failure := vtCli.toggleMutes(42, testCase.keywords)
+			if muted != testCase.muted || failure != "" {
+				t.Fatalf("expected muted %t, got %t %q", testCase.muted, muted, failure)
+			}
+
+			mutes, err := vtCli.loadMutes(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(mutes, testCase.mutes) {
+				t.Errorf("expected mutes %v, got %v", testCase.mutes, mutes)
+			}
+		})
+	}
+}
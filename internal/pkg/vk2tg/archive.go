@@ -0,0 +1,336 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// archiveKey is the storage key of the list of forwarded posts.
+	archiveKey = "archive"
+	// archiveSize is the number of forwarded posts kept in the archive.
+	archiveSize = 5000
+	// historyDefault is the number of posts /history shows without an argument.
+	historyDefault = 10
+	// historyMax is the max number of posts /history and /search show.
+	historyMax = 50
+)
+
+// archivedPost is a forwarded post as kept in the archive.
+type archivedPost struct {
+	PostID     int           `json:"postId"`
+	OwnerID    int           `json:"ownerId"`
+	SignerID   int           `json:"signerId,omitempty"`
+	Source     string        `json:"source"`
+	Text       string        `json:"text"`
+	Link       string        `json:"link,omitempty"`
+	Date       time.Time     `json:"date"`
+	Forwarded  time.Time     `json:"forwarded"`
+	Media      []string      `json:"media,omitempty"`
+	Deliveries []sentMessage `json:"deliveries"`
+}
+
+// archivePost records a delivered post together with the messages it was sent as.
+func (vtCli *VTClinent) archivePost(post *vkObject.WallWallpost, deliveries []sentMessage) {
+	entry := archivedPost{
+		PostID:     post.ID,
+		OwnerID:    post.OwnerID,
+		SignerID:   post.SignerID,
+		Source:     vtCli.sourceName(post.OwnerID),
+		Text:       post.Text,
+		Link:       post.Copyright.Link,
+		Date:       time.Unix(int64(post.Date), 0),
+		Forwarded:  time.Now(),
+		Deliveries: deliveries,
+	}
+
+	for index := range post.Attachments {
+		if post.Attachments[index].Type != "photo" {
+			continue
+		}
+
+		if url := photoURL(&post.Attachments[index].Photo); url != "" {
+			entry.Media = append(entry.Media, url)
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't encode archive entry: %s", post.ID, err)
+
+		return
+	}
+
+	err = vtCli.storage.Push(archiveKey, data, archiveSize)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't archive: %s", post.ID, err)
+	}
+}
+
+// archivedPosts returns up to count latest archived posts, newest first.
+func (vtCli *VTClinent) archivedPosts(count int) ([]*archivedPost, error) {
+	items, err := vtCli.storage.Range(archiveKey, count)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*archivedPost, 0, len(items))
+
+	for _, item := range items {
+		post := new(archivedPost)
+
+		err = json.Unmarshal(item, post)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't decode archive entry")
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+// wallPost rebuilds a post from the archive good enough to be sent again.
+func (post *archivedPost) wallPost() *vkObject.WallWallpost {
+	wallPost := &vkObject.WallWallpost{
+		ID:        post.PostID,
+		OwnerID:   post.OwnerID,
+		SignerID:  post.SignerID,
+		Text:      post.Text,
+		Date:      int(post.Date.Unix()),
+		Copyright: vkObject.WallPostCopyright{Link: post.Link},
+	}
+
+	for _, url := range post.Media {
+		wallPost.Attachments = append(wallPost.Attachments, vkObject.WallWallpostAttachment{
+			Type: "photo",
+			Photo: vkObject.PhotosPhoto{
+				Sizes: []vkObject.PhotosPhotoSizes{{BaseImage: vkObject.BaseImage{URL: url, Width: 1, Height: 1}}},
+			},
+		})
+	}
+
+	return wallPost
+}
+
+func (post *archivedPost) url() string {
+	return postURL(post.wallPost())
+}
+
+// line renders the post as a single line of a /history or /search reply.
+func (post *archivedPost) line() string {
+	return fmt.Sprintf("%s <code>%d_%d</code> [%s] <a href=\"%s\">%s</a>",
+		post.Forwarded.In(zone).Format("02.01 15:04"),
+		post.OwnerID, post.PostID,
+		html.EscapeString(post.Source),
+		html.EscapeString(post.url()),
+		html.EscapeString(summarize(post.Text, digestSummaryLength)),
+	)
+}
+
+// matches reports whether the archived post text contains every word.
+func (post *archivedPost) matches(words []string) bool {
+	text := strings.ToLower(post.Text)
+
+	for _, word := range words {
+		if !strings.Contains(text, strings.ToLower(word)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// postRef returns the "<owner>_<post>" reference of the post.
+func postRef(post *vkObject.WallWallpost) string {
+	return strconv.Itoa(post.OwnerID) + "_" + strconv.Itoa(post.ID)
+}
+
+// parsePostRef parses "-57692133_123", "wall-57692133_123", a full VK link or a bare post ID.
+func parsePostRef(ref string) (int, int, error) {
+	ref = ref[strings.LastIndex(ref, "/")+1:]
+	ref = strings.TrimPrefix(ref, "wall")
+
+	owner, post, found := strings.Cut(ref, "_")
+	if !found {
+		postID, err := strconv.Atoi(ref)
+		if err != nil {
+			return 0, 0, errors.Newf("%q is not a post reference", ref)
+		}
+
+		return 0, postID, nil
+	}
+
+	ownerID, err := strconv.Atoi(owner)
+	if err != nil {
+		return 0, 0, errors.Newf("%q is not a post reference", ref)
+	}
+
+	postID, err := strconv.Atoi(post)
+	if err != nil {
+		return 0, 0, errors.Newf("%q is not a post reference", ref)
+	}
+
+	return ownerID, postID, nil
+}
+
+// isAdmin reports whether the user may run admin commands.
+func (vtCli *VTClinent) isAdmin(user *tb.User) bool {
+	if user == nil {
+		return false
+	}
+
+	if user.ID == vtCli.config.TGUser {
+		return true
+	}
+
+	for _, admin := range vtCli.admins {
+		if admin == user.ID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (vtCli *VTClinent) history(tbContext tb.Context) error {
+	if !vtCli.isAdmin(tbContext.Sender()) {
+		return nil
+	}
+
+	count := historyDefault
+
+	if arg := tbContext.Message().Payload; arg != "" {
+		parsed, err := strconv.Atoi(arg)
+		if err != nil || parsed <= 0 {
+			return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "history.usage")), "error on sending message")
+		}
+
+		count = min(parsed, historyMax)
+	}
+
+	posts, err := vtCli.archivedPosts(count)
+	if err != nil {
+		vtCli.logger.Printf("Can't read archive: %s", err)
+
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "archive.error")), "error on sending message")
+	}
+
+	return vtCli.sendPostList(tbContext, "history.title", posts)
+}
+
+func (vtCli *VTClinent) search(tbContext tb.Context) error {
+	if !vtCli.isAdmin(tbContext.Sender()) {
+		return nil
+	}
+
+	words := strings.Fields(tbContext.Message().Payload)
+	if len(words) == 0 {
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "search.usage")), "error on sending message")
+	}
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		vtCli.logger.Printf("Can't read archive: %s", err)
+
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "archive.error")), "error on sending message")
+	}
+
+	var found []*archivedPost
+
+	for _, post := range posts {
+		if post.matches(words) {
+			found = append(found, post)
+		}
+
+		if len(found) == historyMax {
+			break
+		}
+	}
+
+	return vtCli.sendPostList(tbContext, "search.title", found)
+}
+
+// sendPostList replies with a line per post under the title, a catalog key.
+func (vtCli *VTClinent) sendPostList(tbContext tb.Context, title string, posts []*archivedPost) error {
+	if len(posts) == 0 {
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "search.nothing")), "error on sending message")
+	}
+
+	lines := make([]string, 0, len(posts)+1)
+	lines = append(lines, vtCli.tr(tbContext, title, len(posts)))
+
+	for _, post := range posts {
+		lines = append(lines, post.line())
+	}
+
+	err := tbContext.Send(strings.Join(lines, "\n"), &tb.SendOptions{
+		ParseMode:             tb.ModeHTML,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error on sending message")
+	}
+
+	return nil
+}
+
+// resend sends an archived post again: "/resend <post> [chat ID]", to the current chat by default.
+func (vtCli *VTClinent) resend(tbContext tb.Context) error {
+	if !vtCli.isAdmin(tbContext.Sender()) {
+		return nil
+	}
+
+	args := tbContext.Args()
+	if len(args) == 0 || len(args) > 2 {
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "resend.usage")), "error on sending message")
+	}
+
+	ownerID, postID, err := parsePostRef(args[0])
+	if err != nil {
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "resend.badRef", args[0])), "error on sending message")
+	}
+
+	chatID := tbContext.Chat().ID
+
+	if len(args) == 2 {
+		chatID, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "resend.badChat")), "error on sending message")
+		}
+	}
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		vtCli.logger.Printf("Can't read archive: %s", err)
+
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "archive.error")), "error on sending message")
+	}
+
+	for _, post := range posts {
+		if post.PostID != postID || (ownerID != 0 && post.OwnerID != ownerID) {
+			continue
+		}
+
+		_, err = vtCli.deliver(&Route{Name: "resend", ChatID: chatID}, post.wallPost(), vtCli.config.Silent)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't resend: %s", post.PostID, err)
+
+			return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "resend.failed", err)), "error on sending message")
+		}
+
+		vtCli.logger.Printf("Post %d: Resent to %d", post.PostID, chatID)
+
+		return nil
+	}
+
+	return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "resend.notFound")), "error on sending message")
+}
@@ -0,0 +1,69 @@
+package vk2tg
+
+import "testing"
+
+// TestParsePostRef tests the accepted forms of post references.
+func TestParsePostRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		owner   int
+		post    int
+		invalid bool
+	}{
+		{name: "owner and post", ref: "-57692133_123", owner: -57692133, post: 123},
+		{name: "wall prefix", ref: "wall-57692133_123", owner: -57692133, post: 123},
+		{name: "link", ref: "https://vk.com/wall-57692133_123", owner: -57692133, post: 123},
+		{name: "bare post ID", ref: "123", post: 123},
+		{name: "user wall", ref: "wall42_7", owner: 42, post: 7},
+		{name: "text", ref: "cat", invalid: true},
+		{name: "bad owner", ref: "club_123", invalid: true},
+		{name: "bad post", ref: "-5_x", invalid: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			owner, post, err := parsePostRef(testCase.ref)
+			if testCase.invalid {
+				if err == nil {
+					t.Errorf("expected an error, got %d_%d", owner, post)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if owner != testCase.owner || post != testCase.post {
+				t.Errorf("expected %d_%d, got %d_%d", testCase.owner, testCase.post, owner, post)
+			}
+		})
+	}
+}
+
+// TestArchivedPostMatches tests that search needs every word, case-insensitively.
+func TestArchivedPostMatches(t *testing.T) {
+	post := &archivedPost{Text: "Найдена рыжая Кошка у метро"}
+
+	tests := []struct {
+		name     string
+		words    []string
+		expected bool
+	}{
+		{"single word", []string{"кошка"}, true},
+		{"all words", []string{"рыжая", "МЕТРО"}, true},
+		{"substring", []string{"кош"}, true},
+		{"one word missing", []string{"кошка", "собака"}, false},
+		{"no words", nil, true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if matched := post.matches(testCase.words); matched != testCase.expected {
+				t.Errorf("expected %v, got %v", testCase.expected, matched)
+			}
+		})
+	}
+}
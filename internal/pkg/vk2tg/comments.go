@@ -0,0 +1,292 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// threadsKey is the storage key of the posts followed for new comments.
+	threadsKey = "threads"
+	// commentsPollPeriod is how often a single post is checked for new comments.
+	commentsPollPeriod = 5 * time.Minute
+	// commentsFetchCount is the number of comments requested per page.
+	commentsFetchCount = 100
+	// commentsMaxPages bounds the pages fetched per poll, older comments past it are skipped.
+	commentsMaxPages = 10
+)
+
+// Comments enables following forwarded posts for new VK comments.
+type Comments struct {
+	Window time.Duration `yaml:"window"`
+}
+
+// commentThread is a forwarded message that receives VK comments as replies.
+type commentThread struct {
+	OwnerID       int       `json:"ownerId"`
+	PostID        int       `json:"postId"`
+	Route         string    `json:"route"`
+	ChatID        int64     `json:"chatId"`
+	MessageID     int       `json:"messageId"`
+	LastCommentID int       `json:"lastCommentId"`
+	LastPoll      time.Time `json:"lastPoll"`
+	Until         time.Time `json:"until"`
+}
+
+func (comments *Comments) init() error {
+	if comments.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+
+	return nil
+}
+
+func (vtCli *VTClinent) loadThreads() ([]*commentThread, error) {
+	data, err := vtCli.storage.Get(threadsKey)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var threads []*commentThread
+
+	err = json.Unmarshal(data, &threads)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode comment threads")
+	}
+
+	return threads, nil
+}
+
+func (vtCli *VTClinent) saveThreads(threads []*commentThread) error {
+	data, err := json.Marshal(threads)
+	if err != nil {
+		return errors.Wrap(err, "can't encode comment threads")
+	}
+
+	return vtCli.storage.Set(threadsKey, data, 0)
+}
+
+// followComments starts following the post for comments on behalf of the route.
+func (vtCli *VTClinent) followComments(route *Route, post *vkObject.WallWallpost, msg *tb.Message) {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	threads, err := vtCli.loadThreads()
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't load comment threads: %s", post.ID, err)
+
+		return
+	}
+
+	threads = append(threads, &commentThread{
+		OwnerID:   post.OwnerID,
+		PostID:    post.ID,
+		Route:     route.Name,
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		LastPoll:  time.Now(),
+		Until:     time.Now().Add(route.Comments.Window),
+	})
+
+	err = vtCli.saveThreads(threads)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't save comment threads: %s", post.ID, err)
+	}
+}
+
+// pollComments forwards new comments of followed posts and forgets posts past their window.
+// Storage is only locked around reading and saving the threads, not while VK and Telegram are called.
+func (vtCli *VTClinent) pollComments() {
+	polled := vtCli.dueThreads()
+
+	for _, thread := range polled {
+		err := vtCli.forwardComments(thread)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't forward comments: %s", thread.PostID, err)
+		}
+	}
+
+	if len(polled) > 0 {
+		vtCli.saveLastComments(polled)
+	}
+}
+
+// dueThreads forgets threads past their window and returns copies of the threads
+// due for a poll, marked as polled.
+func (vtCli *VTClinent) dueThreads() []*commentThread {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	threads, err := vtCli.loadThreads()
+	if err != nil {
+		vtCli.logger.Printf("Can't load comment threads: %s", err)
+
+		return nil
+	}
+
+	if len(threads) == 0 {
+		return nil
+	}
+
+	var (
+		active = threads[:0]
+		due    []*commentThread
+	)
+
+	for _, thread := range threads {
+		if time.Now().After(thread.Until) {
+			continue
+		}
+
+		active = append(active, thread)
+
+		if time.Since(thread.LastPoll) < commentsPollPeriod {
+			continue
+		}
+
+		thread.LastPoll = time.Now()
+
+		polled := *thread
+		due = append(due, &polled)
+	}
+
+	err = vtCli.saveThreads(active)
+	if err != nil {
+		vtCli.logger.Printf("Can't save comment threads: %s", err)
+
+		return nil
+	}
+
+	return due
+}
+
+// saveLastComments records the last forwarded comment of the polled threads.
+func (vtCli *VTClinent) saveLastComments(polled []*commentThread) {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	threads, err := vtCli.loadThreads()
+	if err != nil {
+		vtCli.logger.Printf("Can't load comment threads: %s", err)
+
+		return
+	}
+
+	for _, thread := range threads {
+		for _, done := range polled {
+			if thread.ChatID == done.ChatID && thread.MessageID == done.MessageID {
+				thread.LastCommentID = max(thread.LastCommentID, done.LastCommentID)
+			}
+		}
+	}
+
+	err = vtCli.saveThreads(threads)
+	if err != nil {
+		vtCli.logger.Printf("Can't save comment threads: %s", err)
+	}
+}
+
+// forwardComments sends comments newer than the last forwarded one as replies to the thread message.
+func (vtCli *VTClinent) forwardComments(thread *commentThread) error {
+	comments, err := vtCli.newComments(thread)
+	if err != nil {
+		return err
+	}
+
+	if len(comments) == 0 {
+		return nil
+	}
+
+	authorIDs := make([]int, 0, len(comments))
+	for index := range comments {
+		authorIDs = append(authorIDs, comments[index].FromID)
+	}
+
+	names := vtCli.resolveNames(authorIDs...)
+
+	for index := range comments {
+		comment := &comments[index]
+
+		_, err = vtCli.tgClient.Send(
+			tb.ChatID(thread.ChatID),
+			"💬 "+names[comment.FromID].Name+": "+comment.Text,
+			&tb.SendOptions{
+				ReplyTo:             &tb.Message{ID: thread.MessageID, Chat: &tb.Chat{ID: thread.ChatID}},
+				DisableNotification: vtCli.config.Silent,
+			},
+		)
+		if err != nil {
+			return errors.Wrap(err, "can't send comment")
+		}
+
+		thread.LastCommentID = comment.ID
+		vtCli.logger.Printf("Post %d: Comment %d forwarded via route %s", thread.PostID, comment.ID, thread.Route)
+	}
+
+	return nil
+}
+
+// newComments pages through the comments of the thread post from the newest one
+// down to the last forwarded one and returns the new comments oldest first.
+func (vtCli *VTClinent) newComments(thread *commentThread) ([]vkObject.WallWallComment, error) {
+	var comments []vkObject.WallWallComment
+
+	seen := make(map[int]bool)
+
+	for page := range commentsMaxPages {
+		response, err := vtCli.vkClient.WallGetComments(vkapi.Params{
+			"owner_id": thread.OwnerID,
+			"post_id":  thread.PostID,
+			"count":    commentsFetchCount,
+			"offset":   page * commentsFetchCount,
+			"sort":     "desc",
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to fetch comments")
+		}
+
+		done := len(response.Items) < commentsFetchCount
+
+		for index := range response.Items {
+			comment := response.Items[index]
+
+			if comment.ID <= thread.LastCommentID {
+				done = true
+
+				continue
+			}
+
+			// Comments posted while paging shift older ones to the next page.
+			if seen[comment.ID] {
+				continue
+			}
+
+			seen[comment.ID] = true
+
+			if !bool(comment.Deleted) && strings.TrimSpace(comment.Text) != "" {
+				comments = append(comments, comment)
+			}
+		}
+
+		if done {
+			break
+		}
+	}
+
+	slices.SortFunc(comments, func(a, b vkObject.WallWallComment) int {
+		return a.ID - b.ID
+	})
+
+	return comments, nil
+}
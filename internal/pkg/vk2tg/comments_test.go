@@ -0,0 +1,185 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	tb "gopkg.in/telebot.v4"
+)
+
+// commentsServer serves total comments through wall.getComments and records the Telegram replies.
+// Requests made while state is set and locked are counted.
+type commentsServer struct {
+	total   int
+	mu      sync.Mutex
+	replies []map[string]string
+	state   *sync.Mutex
+	locked  int
+}
+
+func (server *commentsServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	if server.state != nil {
+		if server.state.TryLock() {
+			server.state.Unlock()
+		} else {
+			server.mu.Lock()
+			server.locked++
+			server.mu.Unlock()
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(request.URL.Path, "/wall.getComments"):
+		offset, _ := strconv.Atoi(request.FormValue("offset"))
+		count, _ := strconv.Atoi(request.FormValue("count"))
+
+		items := []vkObject.WallWallComment{}
+
+		for index := offset; index < server.total && len(items) < count; index++ {
+			id := server.total - index
+			if request.FormValue("sort") == "asc" {
+				id = index + 1
+			}
+
+			items = append(items, vkObject.WallWallComment{ID: id, FromID: 1, Text: "comment " + strconv.Itoa(id)})
+		}
+
+		_ = json.NewEncoder(writer).Encode(map[string]any{"response": map[string]any{"count": server.total, "items": items}})
+	case strings.HasSuffix(request.URL.Path, "/sendMessage"):
+		params := make(map[string]string)
+		_ = json.NewDecoder(request.Body).Decode(&params)
+
+		server.mu.Lock()
+		server.replies = append(server.replies, params)
+		server.mu.Unlock()
+
+		_, _ = fmt.Fprintf(writer, `{"ok":true,"result":{"message_id":%d,"chat":{"id":-100}}}`, len(server.replies)+100)
+	default:
+		_, _ = writer.Write([]byte(`{"response":[]}`))
+	}
+}
+
+// TestForwardComments tests that new comments past a page are forwarded oldest first as replies to the thread.
+func TestForwardComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		last     int
+		expected []int
+	}{
+		{"nothing new", 50, 50, nil},
+		{"single page", 50, 45, []int{46, 47, 48, 49, 50}},
+		{"several pages", 250, 120, seq(121, 250)},
+		{"first poll", 150, 0, seq(1, 150)},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := &commentsServer{total: testCase.total}
+			httpServer := httptest.NewServer(server)
+
+			defer httpServer.Close()
+
+			vtCli := NewVTClient("", "", 0, 1)
+			vtCli.vkClient = vkapi.NewVK("token")
+			vtCli.vkClient.MethodURL = httpServer.URL + "/method/"
+
+			var err error
+
+			vtCli.tgClient, err = tb.NewBot(tb.Settings{Token: "token", URL: httpServer.URL, Offline: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			thread := &commentThread{OwnerID: -5, PostID: 1, ChatID: -100, MessageID: 7, LastCommentID: testCase.last}
+
+			err = vtCli.forwardComments(thread)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var forwarded []int
+
+			for _, reply := range server.replies {
+				if reply["reply_to_message_id"] != "7" {
+					t.Fatalf("expected a reply to message 7, got %v", reply)
+				}
+
+				id, _ := strconv.Atoi(reply["text"][strings.LastIndex(reply["text"], " ")+1:])
+				forwarded = append(forwarded, id)
+			}
+
+			if !slices.Equal(forwarded, testCase.expected) {
+				t.Errorf("expected %v, got %v", testCase.expected, forwarded)
+			}
+
+			if want := max(testCase.last, testCase.total); thread.LastCommentID != want {
+				t.Errorf("expected last comment %d, got %d", want, thread.LastCommentID)
+			}
+		})
+	}
+}
+
+// TestPollComments tests that polling saves the last forwarded comment without locking state during requests.
+func TestPollComments(t *testing.T) {
+	server := &commentsServer{total: 3}
+	httpServer := httptest.NewServer(server)
+
+	defer httpServer.Close()
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.vkClient = vkapi.NewVK("token")
+	vtCli.vkClient.MethodURL = httpServer.URL + "/method/"
+	server.state = &vtCli.stateMu
+
+	var err error
+
+	vtCli.tgClient, err = tb.NewBot(tb.Settings{Token: "token", URL: httpServer.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vtCli.saveThreads([]*commentThread{
+		{OwnerID: -5, PostID: 1, ChatID: -100, MessageID: 7, Until: time.Now().Add(time.Hour)},
+		{OwnerID: -5, PostID: 2, ChatID: -100, MessageID: 8, Until: time.Now().Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli.pollComments()
+
+	threads, err := vtCli.loadThreads()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(threads) != 1 || threads[0].LastCommentID != 3 || threads[0].LastPoll.IsZero() {
+		t.Errorf("expected the expired thread dropped and the other one polled, got %+v", threads)
+	}
+
+	if len(server.replies) != 3 || server.locked != 0 {
+		t.Errorf("expected 3 replies sent without the state lock, got %d, %d locked", len(server.replies), server.locked)
+	}
+}
+
+func seq(from, to int) []int {
+	result := make([]int, 0, to-from+1)
+	for id := from; id <= to; id++ {
+		result = append(result, id)
+	}
+
+	return result
+}
@@ -0,0 +1,471 @@
+package vk2tg
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"html"
+	"image"
+	_ "image/jpeg" // VK photos are JPEG
+	_ "image/png"  // some VK photos are PNG
+	"math/bits"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// Duplicate handling modes.
+const (
+	// DedupModeSuppress drops near-duplicates silently.
+	DedupModeSuppress = "suppress"
+	// DedupModeCollapse edits the first message to mention where else the post appeared.
+	DedupModeCollapse = "collapse"
+)
+
+const (
+	// shingleSize is the number of words in a text shingle.
+	shingleSize = 3
+	// signatureSize is the number of MinHash values kept per text.
+	signatureSize = 64
+	// maxPhotoDistance is the max Hamming distance between hashes of the same photo.
+	maxPhotoDistance = 6
+	// photoHashTimeout limits the download of a single photo for hashing.
+	photoHashTimeout = 10 * time.Second
+	// defaultDedupThreshold is the text similarity above which posts are duplicates.
+	defaultDedupThreshold = 0.7
+	// fingerprintsKey is the storage key of the recent fingerprints.
+	fingerprintsKey = "fingerprints"
+)
+
+// Dedup suppresses posts that were already forwarded from another source.
+type Dedup struct {
+	Window    time.Duration `yaml:"window"`
+	Threshold float64       `yaml:"threshold"`
+	Mode      string        `yaml:"mode"`
+}
+
+// fingerprint describes a forwarded post well enough to recognise its copies.
+type fingerprint struct {
+	PostID   int           `json:"postId"`
+	OwnerID  int           `json:"ownerId"`
+	SignerID int           `json:"signerId,omitempty"`
+	Source   string        `json:"source"`
+	Seen     time.Time     `json:"seen"`
+	Text     []uint64      `json:"text,omitempty"`
+	Photos   []uint64      `json:"photos,omitempty"`
+	Body     string        `json:"body,omitempty"`
+	Messages []sentMessage `json:"messages,omitempty"`
+	AlsoIn   []string      `json:"alsoIn,omitempty"`
+}
+
+// sentMessage references a Telegram message a post was delivered as, or the route sink it was handed to.
+type sentMessage struct {
+	Route     string `json:"route"`
+	Sink      string `json:"sink,omitempty"`
+	ChatID    int64  `json:"chatId"`
+	MessageID int    `json:"messageId"`
+}
+
+// delivered reports whether the post was delivered to the route chat, or to the sink when it is set.
+func delivered(deliveries []sentMessage, route, sink string) bool {
+	return slices.ContainsFunc(deliveries, func(delivery sentMessage) bool {
+		return delivery.Route == route && delivery.Sink == sink
+	})
+}
+
+func (dedup *Dedup) init() error {
+	if dedup.Window <= 0 {
+		return errors.New("window must be positive")
+	}
+
+	if dedup.Threshold == 0 {
+		dedup.Threshold = defaultDedupThreshold
+	}
+
+	if dedup.Threshold < 0 || dedup.Threshold > 1 {
+		return errors.Newf("threshold %v is not within [0, 1]", dedup.Threshold)
+	}
+
+	switch dedup.Mode {
+	case "":
+		dedup.Mode = DedupModeSuppress
+	case DedupModeSuppress, DedupModeCollapse:
+	default:
+		return errors.Newf("unknown mode %q", dedup.Mode)
+	}
+
+	return nil
+}
+
+// isDuplicate compares two fingerprints.
+// Texts have to be similar enough; a shared photo lowers the bar by half
+// and is sufficient on its own when neither post has meaningful text.
+func (dedup *Dedup) isDuplicate(left, right *fingerprint) bool {
+	textSimilarity := similarity(left.Text, right.Text)
+	if textSimilarity >= dedup.Threshold {
+		return true
+	}
+
+	if !sharePhoto(left.Photos, right.Photos) {
+		return false
+	}
+
+	if len(left.Text) == 0 && len(right.Text) == 0 {
+		return true
+	}
+
+	return textSimilarity >= dedup.Threshold/2
+}
+
+// fingerprint builds the fingerprint of a post, downloading its photos for hashing.
+func (vtCli *VTClinent) fingerprint(post *vkObject.WallWallpost) *fingerprint {
+	entry := &fingerprint{
+		PostID:   post.ID,
+		OwnerID:  post.OwnerID,
+		SignerID: post.SignerID,
+		Source:   vtCli.sourceName(post.OwnerID),
+		Seen:     time.Now(),
+		Text:     textSignature(post.Text),
+	}
+
+	if vtCli.dedup.Mode == DedupModeCollapse {
+		entry.Body = post.Text
+	}
+
+	for index := range post.Attachments {
+		if post.Attachments[index].Type != "photo" {
+			continue
+		}
+
+		url := smallestPhotoURL(&post.Attachments[index].Photo)
+		if url == "" {
+			continue
+		}
+
+		hash, err := photoHash(url)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't hash photo: %s", post.ID, err)
+
+			continue
+		}
+
+		entry.Photos = append(entry.Photos, hash)
+	}
+
+	return entry
+}
+
+// loadFingerprints returns fingerprints seen within the dedup window.
+func (vtCli *VTClinent) loadFingerprints() ([]*fingerprint, error) {
+	data, err := vtCli.storage.Get(fingerprintsKey)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*fingerprint
+
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode fingerprints")
+	}
+
+	horizon := time.Now().Add(-vtCli.dedup.Window)
+
+	return slices.DeleteFunc(entries, func(entry *fingerprint) bool {
+		return entry.Seen.Before(horizon)
+	}), nil
+}
+
+func (vtCli *VTClinent) saveFingerprints(entries []*fingerprint) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "can't encode fingerprints")
+	}
+
+	return vtCli.storage.Set(fingerprintsKey, data, vtCli.dedup.Window)
+}
+
+// checkDuplicate reports whether the post is a near-duplicate of a recently forwarded one
+// and handles it according to the dedup mode.
+func (vtCli *VTClinent) checkDuplicate(entry *fingerprint) bool {
+	entries, err := vtCli.loadFingerprints()
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't check for duplicates: %s", entry.PostID, err)
+
+		return false
+	}
+
+	original := vtCli.findDuplicate(entries, entry)
+	if original == nil {
+		return false
+	}
+
+	vtCli.logger.Printf("Post %d: Duplicate of post %d from %s", entry.PostID, original.PostID, original.Source)
+
+	if vtCli.dedup.Mode == DedupModeCollapse && original.Source != entry.Source &&
+		!slices.Contains(original.AlsoIn, entry.Source) {
+		original.AlsoIn = append(original.AlsoIn, entry.Source)
+		vtCli.collapse(original)
+
+		err = vtCli.saveFingerprints(entries)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't save fingerprints: %s", entry.PostID, err)
+		}
+	}
+
+	return true
+}
+
+// findDuplicate returns the first of entries the post is a near-duplicate of, if any.
+func (vtCli *VTClinent) findDuplicate(entries []*fingerprint, entry *fingerprint) *fingerprint {
+	for _, original := range entries {
+		if vtCli.dedup.isDuplicate(original, entry) {
+			return original
+		}
+	}
+
+	return nil
+}
+
+// simulateDuplicate is checkDuplicate without side effects: stored fingerprints are only read
+// and posts simulated earlier in the run count as forwarded.
+func (vtCli *VTClinent) simulateDuplicate(entry *fingerprint) *fingerprint {
+	entries, err := vtCli.loadFingerprints()
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't check for duplicates: %s", entry.PostID, err)
+	}
+
+	original := vtCli.findDuplicate(append(entries, vtCli.simulated...), entry)
+	if original == nil {
+		vtCli.simulated = append(vtCli.simulated, entry)
+	}
+
+	return original
+}
+
+// rememberPost stores the fingerprint of a delivered post.
+func (vtCli *VTClinent) rememberPost(entry *fingerprint) {
+	entries, err := vtCli.loadFingerprints()
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't load fingerprints: %s", entry.PostID, err)
+	}
+
+	err = vtCli.saveFingerprints(append(entries, entry))
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't save fingerprints: %s", entry.PostID, err)
+	}
+}
+
+// collapse edits the messages of the original post to list the other sources it appeared in.
+func (vtCli *VTClinent) collapse(original *fingerprint) {
+	post := &vkObject.WallWallpost{
+		ID:       original.PostID,
+		OwnerID:  original.OwnerID,
+		SignerID: original.SignerID,
+		Text:     original.Body,
+	}
+
+	for _, message := range original.Messages {
+		// Sinks keep no message to edit.
+		if message.Sink != "" {
+			continue
+		}
+
+		route := vtCli.route(message.Route)
+		if route == nil {
+			continue
+		}
+
+		text, options, err := vtCli.renderPost(route, message.ChatID, post, true)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't render for route %s: %s", original.PostID, route.Name, err)
+
+			continue
+		}
+
+		alsoIn := strings.Join(original.AlsoIn, ", ")
+		if options.ParseMode == tb.ModeHTML {
+			alsoIn = html.EscapeString(alsoIn)
+		}
+
+		_, err = vtCli.tgClient.Edit(
+			&tb.StoredMessage{MessageID: strconv.Itoa(message.MessageID), ChatID: message.ChatID},
+			text+"\n\n"+translate(vtCli.chatLanguage(message.ChatID), "dedup.also", alsoIn),
+			options,
+		)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't collapse message in route %s: %s", original.PostID, message.Route, err)
+		}
+	}
+}
+
+// textSignature returns the MinHash signature of the word shingles of text.
+func textSignature(text string) []uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+	})
+
+	if len(words) == 0 {
+		return nil
+	}
+
+	var shingles []uint64
+
+	for index := 0; index+shingleSize <= len(words) || index == 0; index++ {
+		hasher := fnv.New64a()
+
+		for _, word := range words[index:min(index+shingleSize, len(words))] {
+			_, _ = hasher.Write([]byte(word))
+			_, _ = hasher.Write([]byte{' '})
+		}
+
+		shingles = append(shingles, hasher.Sum64())
+	}
+
+	signature := make([]uint64, signatureSize)
+
+	for seed := range signature {
+		signature[seed] = ^uint64(0)
+
+		for _, shingle := range shingles {
+			signature[seed] = min(signature[seed], mix(shingle^uint64(seed+1)))
+		}
+	}
+
+	return signature
+}
+
+// similarity estimates the Jaccard similarity of two texts by their signatures.
+func similarity(left, right []uint64) float64 {
+	if len(left) != signatureSize || len(right) != signatureSize {
+		return 0
+	}
+
+	var equal int
+
+	for index := range left {
+		if left[index] == right[index] {
+			equal++
+		}
+	}
+
+	return float64(equal) / signatureSize
+}
+
+// mix is the splitmix64 finalizer, used to derive independent hash functions.
+//
+//nolint:mnd // well-known constants
+func mix(value uint64) uint64 {
+	value ^= value >> 30
+	value *= 0xbf58476d1ce4e5b9
+	value ^= value >> 27
+	value *= 0x94d049bb133111eb
+	value ^= value >> 31
+
+	return value
+}
+
+func sharePhoto(left, right []uint64) bool {
+	for _, leftHash := range left {
+		for _, rightHash := range right {
+			if bits.OnesCount64(leftHash^rightHash) <= maxPhotoDistance {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// smallestPhotoURL returns the URL of the smallest size of the photo, enough for hashing.
+func smallestPhotoURL(photo *vkObject.PhotosPhoto) string {
+	var (
+		minSize float64
+		url     string
+	)
+
+	for sizeIndex := range photo.Sizes {
+		size := photo.Sizes[sizeIndex].Width * photo.Sizes[sizeIndex].Height
+		if size > 0 && (url == "" || size < minSize) {
+			minSize = size
+			url = photo.Sizes[sizeIndex].URL
+		}
+	}
+
+	return url
+}
+
+// photoHash downloads the image and computes its difference hash.
+func photoHash(url string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), photoHashTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot create request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot download photo")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot decode photo")
+	}
+
+	return differenceHash(img), nil
+}
+
+// differenceHash is a 64-bit perceptual hash: the image is scaled down to 9x8 grey pixels
+// and every bit tells whether a pixel is brighter than its right neighbour.
+func differenceHash(img image.Image) uint64 {
+	const (
+		width  = 9
+		height = 8
+	)
+
+	bounds := img.Bounds()
+
+	var grey [height][width]uint32
+
+	for row := range height {
+		for col := range width {
+			x := bounds.Min.X + (col*bounds.Dx()+bounds.Dx()/2)/width
+			y := bounds.Min.Y + (row*bounds.Dy()+bounds.Dy()/2)/height
+			red, green, blue, _ := img.At(x, y).RGBA()
+			//nolint:mnd // ITU-R 601 luma
+			grey[row][col] = (299*red + 587*green + 114*blue) / 1000
+		}
+	}
+
+	var hash uint64
+
+	for row := range height {
+		for col := range width - 1 {
+			hash <<= 1
+			if grey[row][col] > grey[row][col+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
@@ -0,0 +1,149 @@
+package vk2tg
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"testing"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+)
+
+// TestDedupIsDuplicate tests near-duplicate detection by text and photos.
+func TestDedupIsDuplicate(t *testing.T) {
+	dedup := &Dedup{Window: 1, Threshold: 0.7}
+
+	err := dedup.init()
+	if err != nil {
+		t.Fatalf("failed to init dedup: %v", err)
+	}
+
+	original := "#поиск Пропала собака! Рыжий пёс по кличке Бублик, район Юго-Западная, 12 мая. " +
+		"Ошейник синий, на левом ухе пятно. Нашедшего просим позвонить по телефону 8-900-000-00-00"
+	repost := "Пропала собака!!! Рыжий пёс по кличке Бублик, район Юго-Западная, 12 мая. " +
+		"Ошейник синий, на левом ухе пятно. Нашедшего просим позвонить по телефону 8-900-000-00-00 #поиск"
+	other := "#поиск Найдена кошка, серая, пушистая, у метро Тёплый Стан. Ищем хозяев, кошка в передержке"
+
+	tests := []struct {
+		name     string
+		left     *fingerprint
+		right    *fingerprint
+		expected bool
+	}{
+		{
+			name:     "same text with different punctuation and hashtag position",
+			left:     &fingerprint{Text: textSignature(original)},
+			right:    &fingerprint{Text: textSignature(repost)},
+			expected: true,
+		},
+		{
+			name:     "different posts",
+			left:     &fingerprint{Text: textSignature(original)},
+			right:    &fingerprint{Text: textSignature(other)},
+			expected: false,
+		},
+		{
+			name:     "same photo without text",
+			left:     &fingerprint{Photos: []uint64{0xF0F0F0F0F0F0F0F0}},
+			right:    &fingerprint{Photos: []uint64{0xF0F0F0F0F0F0F0F1}},
+			expected: true,
+		},
+		{
+			name:     "same photo but different text",
+			left:     &fingerprint{Text: textSignature(original), Photos: []uint64{1}},
+			right:    &fingerprint{Text: textSignature(other), Photos: []uint64{1}},
+			expected: false,
+		},
+		{
+			name:     "different photos without text",
+			left:     &fingerprint{Photos: []uint64{0}},
+			right:    &fingerprint{Photos: []uint64{^uint64(0)}},
+			expected: false,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := dedup.isDuplicate(testCase.left, testCase.right)
+
+			if result != testCase.expected {
+				t.Errorf("expected %t, got %t (similarity %.2f)",
+					testCase.expected, result, similarity(testCase.left.Text, testCase.right.Text))
+			}
+		})
+	}
+}
+
+// TestDifferenceHashScaling tests that resized copies of an image hash alike.
+func TestDifferenceHashScaling(t *testing.T) {
+	gradient := func(width, height int) image.Image {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+
+		for y := range height {
+			for x := range width {
+				relX := float64(x) * 100 / float64(width)
+				relY := float64(y) * 100 / float64(height)
+				img.SetGray(x, y, color.Gray{Y: uint8(128 + 100*math.Sin(relX/7)*math.Cos(relY/9))})
+			}
+		}
+
+		return img
+	}
+
+	small := differenceHash(gradient(90, 80))
+	large := differenceHash(gradient(900, 800))
+
+	if distance := bits.OnesCount64(small ^ large); distance > maxPhotoDistance {
+		t.Errorf("expected hashes to be close, distance is %d", distance)
+	}
+
+	if small == 0 || small == ^uint64(0) {
+		t.Errorf("expected a non-trivial hash, got %x", small)
+	}
+}
+
+// TestRememberMatched tests that only posts some route took are remembered as originals.
+func TestRememberMatched(t *testing.T) {
+	// A digest route takes posts without sending anything right away.
+	route := &Route{Name: "search", Filter: "#поиск", Digest: &Digest{Every: time.Hour}}
+
+	err := route.Digest.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.dedup = &Dedup{Window: time.Hour}
+	vtCli.routes = []*Route{route}
+
+	err = vtCli.dedup.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := "Пропала собака, рыжий пёс по кличке Бублик, район Юго-Западная"
+
+	vtCli.process(&vkObject.WallWallpost{ID: 1, OwnerID: -5, Text: text})
+
+	entries, err := vtCli.loadFingerprints()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("expected the unmatched post to be forgotten, got %+v", entries)
+	}
+
+	vtCli.process(&vkObject.WallWallpost{ID: 2, OwnerID: -5, Text: "#поиск " + text})
+
+	entries, err = vtCli.loadFingerprints()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].PostID != 2 {
+		t.Errorf("expected the matched post to be remembered, got %+v", entries)
+	}
+}
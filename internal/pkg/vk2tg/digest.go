@@ -0,0 +1,295 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// digestSummaryLength is the max length of a single digest line in runes.
+	digestSummaryLength = 80
+	// digestMaxItems is the default limit of lines in a digest message.
+	digestMaxItems = 30
+)
+
+// Digest batches route posts into periodic summaries instead of sending them one by one.
+type Digest struct {
+	Every    time.Duration `yaml:"every"`
+	Timezone string        `yaml:"timezone"`
+	MaxItems int           `yaml:"maxItems"`
+
+	location *time.Location
+}
+
+// digestBatch is the pending digest of a route as persisted in storage.
+type digestBatch struct {
+	Since time.Time    `json:"since"`
+	Items []digestItem `json:"items"`
+}
+
+type digestItem struct {
+	PostID   int    `json:"postId"`
+	URL      string `json:"url"`
+	Summary  string `json:"summary"`
+	PhotoURL string `json:"photoUrl,omitempty"`
+	PhotoKey string `json:"photoKey,omitempty"`
+	// Post is archived once the digest is sent
+	Post *vkObject.WallWallpost `json:"post,omitempty"`
+}
+
+func (digest *Digest) init() error {
+	if digest.Every <= 0 {
+		return errors.New("digest period must be positive")
+	}
+
+	if digest.MaxItems <= 0 {
+		digest.MaxItems = digestMaxItems
+	}
+
+	digest.location = zone
+
+	if digest.Timezone != "" {
+		var err error
+
+		digest.location, err = time.LoadLocation(digest.Timezone)
+		if err != nil {
+			return errors.Wrap(err, "unknown timezone")
+		}
+	}
+
+	return nil
+}
+
+// due returns when a batch started at since has to be sent.
+// Periods are aligned to local midnight, so "1h" fires at the top of every hour
+// and "24h" right after midnight.
+func (digest *Digest) due(since time.Time) time.Time {
+	local := since.In(digest.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, digest.location)
+	periods := local.Sub(midnight)/digest.Every + 1
+
+	return midnight.Add(periods * digest.Every)
+}
+
+func digestKey(route *Route) string {
+	return "digest:" + route.Name
+}
+
+func (vtCli *VTClinent) loadDigest(route *Route) (*digestBatch, error) {
+	data, err := vtCli.storage.Get(digestKey(route))
+	if errors.Is(err, errNotFound) {
+		return nil, nil //nolint:nilnil // no pending digest
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	batch := new(digestBatch)
+
+	err = json.Unmarshal(data, batch)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode digest")
+	}
+
+	return batch, nil
+}
+
+// addToDigest appends the post to the pending digest of the route.
+func (vtCli *VTClinent) addToDigest(route *Route, post *vkObject.WallWallpost) error {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	batch, err := vtCli.loadDigest(route)
+	if err != nil {
+		return err
+	}
+
+	if batch == nil {
+		batch = &digestBatch{Since: time.Now()}
+	}
+
+	item := digestItem{
+		PostID:  post.ID,
+		URL:     postURL(post),
+		Summary: summarize(post.Text, digestSummaryLength),
+		Post:    post,
+	}
+
+	if photo := firstPhoto(post); photo != nil {
+		item.PhotoURL = photoURL(photo)
+		item.PhotoKey = photoKey(photo)
+	}
+
+	batch.Items = append(batch.Items, item)
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Wrap(err, "can't encode digest")
+	}
+
+	return vtCli.storage.Set(digestKey(route), data, 0)
+}
+
+// sendDigests sends every pending digest whose period is over.
+// The state lock is only held to load and clear a batch, not while it is sent.
+func (vtCli *VTClinent) sendDigests() {
+	for _, route := range vtCli.routes {
+		if route.Digest == nil {
+			continue
+		}
+
+		batch, err := vtCli.lockedDigest(route)
+		if err != nil {
+			vtCli.logger.Printf("Can't load digest of route %s: %s", route.Name, err)
+
+			continue
+		}
+
+		if batch == nil || time.Now().Before(route.Digest.due(batch.Since)) {
+			continue
+		}
+
+		silent := vtCli.config.Silent
+
+		if route.QuietHours != nil {
+			if _, quiet := route.QuietHours.ActiveUntil(time.Now()); quiet {
+				if route.QuietHours.Mode == QuietModeHold {
+					continue
+				}
+
+				silent = true
+			}
+		}
+
+		msg, err := vtCli.deliverDigest(route, batch, silent)
+		if err != nil {
+			vtCli.count(statsRoutes, route.Name, counterFailed, 1)
+			vtCli.logger.Printf("Can't send digest of route %s: %s", route.Name, err)
+
+			continue
+		}
+
+		vtCli.count(statsRoutes, route.Name, counterDelivered, int64(len(batch.Items)))
+
+		for _, item := range batch.Items {
+			if item.Post != nil {
+				vtCli.archivePost(item.Post, []sentMessage{{Route: route.Name, ChatID: msg.Chat.ID, MessageID: msg.ID}})
+			}
+		}
+
+		err = vtCli.clearDigest(route, len(batch.Items))
+		if err != nil {
+			vtCli.logger.Printf("Can't clear digest of route %s: %s", route.Name, err)
+		}
+
+		vtCli.logger.Printf("Digest of %d posts sent via route %s", len(batch.Items), route.Name)
+	}
+}
+
+func (vtCli *VTClinent) lockedDigest(route *Route) (*digestBatch, error) {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	return vtCli.loadDigest(route)
+}
+
+// clearDigest drops the first sent items of the route digest,
+// posts added while it was being sent start the next one.
+func (vtCli *VTClinent) clearDigest(route *Route, sent int) error {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	batch, err := vtCli.loadDigest(route)
+	if err != nil {
+		return err
+	}
+
+	if batch == nil {
+		return nil
+	}
+
+	if sent >= len(batch.Items) {
+		return vtCli.storage.Delete(digestKey(route))
+	}
+
+	batch.Since = time.Now()
+	batch.Items = batch.Items[sent:]
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Wrap(err, "can't encode digest")
+	}
+
+	return vtCli.storage.Set(digestKey(route), data, 0)
+}
+
+func (vtCli *VTClinent) deliverDigest(route *Route, batch *digestBatch, silent bool) (*tb.Message, error) {
+	recipient := tb.ChatID(route.ChatID)
+	items := batch.Items
+
+	if len(items) > route.Digest.MaxItems {
+		items = items[len(items)-route.Digest.MaxItems:]
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString(translate(vtCli.chatLanguage(route.ChatID), "digest.head", len(batch.Items)))
+
+	for _, item := range items {
+		fmt.Fprintf(&builder, "\n• <a href=\"%s\">%s</a>", html.EscapeString(item.URL), html.EscapeString(item.Summary))
+	}
+
+	if skipped := len(batch.Items) - len(items); skipped > 0 {
+		fmt.Fprintf(&builder, "\n… +%d", skipped)
+	}
+
+	for _, item := range items {
+		if item.PhotoURL == "" {
+			continue
+		}
+
+		vtCli.sendPhoto(recipient, item.PhotoKey, item.PhotoURL, route.Images, silent)
+
+		break
+	}
+
+	msg, err := vtCli.tgClient.Send(recipient, builder.String(), &tb.SendOptions{
+		ParseMode:             tb.ModeHTML,
+		DisableNotification:   silent,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't send digest")
+	}
+
+	return msg, nil
+}
+
+// summarize returns the first non-empty line of text cut to limit runes.
+func summarize(text string, limit int) string {
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(line) <= limit {
+			return line
+		}
+
+		runes := []rune(line)
+
+		return strings.TrimSpace(string(runes[:limit-1])) + "…"
+	}
+
+	return "…"
+}
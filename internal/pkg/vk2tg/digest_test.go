@@ -0,0 +1,232 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestDigestDue tests that digest periods are aligned to local midnight.
+func TestDigestDue(t *testing.T) {
+	tests := []struct {
+		name     string
+		every    time.Duration
+		since    time.Time
+		expected time.Time
+	}{
+		{
+			name:     "hourly fires at the top of the next hour",
+			every:    time.Hour,
+			since:    time.Date(2026, 10, 14, 10, 25, 0, 0, zone),
+			expected: time.Date(2026, 10, 14, 11, 0, 0, 0, zone),
+		},
+		{
+			name:     "batch started on a boundary waits a full period",
+			every:    time.Hour,
+			since:    time.Date(2026, 10, 14, 10, 0, 0, 0, zone),
+			expected: time.Date(2026, 10, 14, 11, 0, 0, 0, zone),
+		},
+		{
+			name:     "daily fires after midnight",
+			every:    24 * time.Hour,
+			since:    time.Date(2026, 10, 14, 18, 0, 0, 0, zone),
+			expected: time.Date(2026, 10, 15, 0, 0, 0, 0, zone),
+		},
+		{
+			name:     "six hours",
+			every:    6 * time.Hour,
+			since:    time.Date(2026, 10, 14, 13, 0, 0, 0, zone),
+			expected: time.Date(2026, 10, 14, 18, 0, 0, 0, zone),
+		},
+		{
+			name:     "since in another zone",
+			every:    24 * time.Hour,
+			since:    time.Date(2026, 10, 14, 22, 30, 0, 0, time.UTC),
+			expected: time.Date(2026, 10, 16, 0, 0, 0, 0, zone),
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			digest := &Digest{Every: testCase.every}
+
+			err := digest.init()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if due := digest.due(testCase.since); !due.Equal(testCase.expected) {
+				t.Errorf("expected %s, got %s", testCase.expected, due)
+			}
+		})
+	}
+}
+
+// TestSummarize tests that the first non-empty line is cut to the limit.
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"first line", "Lost cat\nsecond line", "Lost cat"},
+		{"leading blank lines", "\n  \n  Найден кот  \nдальше", "Найден кот"},
+		{"long line", strings.Repeat("ы", 20), strings.Repeat("ы", 9) + "…"},
+		{"exact limit", strings.Repeat("a", 10), strings.Repeat("a", 10)},
+		{"empty", " \n ", "…"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if summary := summarize(testCase.text, 10); summary != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, summary)
+			}
+		})
+	}
+}
+
+// TestDigestArchive tests that posts of a sent digest are archived with the digest message.
+func TestDigestArchive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"ok":true,"result":{"message_id":9,"chat":{"id":-100}}}`))
+	}))
+	defer server.Close()
+
+	bot, err := tb.NewBot(tb.Settings{Token: "token", URL: server.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route := &Route{Name: "daily", ChatID: -100, Digest: &Digest{Every: time.Hour}}
+
+	err = route.Digest.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.tgClient = bot
+	vtCli.routes = []*Route{route}
+
+	for _, post := range []*vkObject.WallWallpost{{ID: 1, OwnerID: -5, Text: "Lost cat"}, {ID: 2, OwnerID: -5, Text: "Found dog"}} {
+		err = vtCli.addToDigest(route, post)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch, err := vtCli.loadDigest(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch.Since = time.Now().Add(-2 * time.Hour)
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vtCli.storage.Set(digestKey(route), data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli.sendDigests()
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posts) != 2 || posts[0].PostID != 2 || posts[1].Text != "Lost cat" {
+		t.Fatalf("expected both posts archived, got %+v", posts)
+	}
+
+	if delivery := posts[0].Deliveries; len(delivery) != 1 || delivery[0] != (sentMessage{Route: "daily", ChatID: -100, MessageID: 9}) {
+		t.Errorf("unexpected deliveries %v", delivery)
+	}
+
+	if batch, _ := vtCli.loadDigest(route); batch != nil {
+		t.Errorf("expected the digest to be cleared, got %+v", batch)
+	}
+}
+
+// TestDigestUnlocked tests that a digest is sent without the state lock
+// and that posts added meanwhile are kept for the next one.
+func TestDigestUnlocked(t *testing.T) {
+	var (
+		vtCli  *VTClinent
+		locked bool
+		added  bool
+	)
+
+	route := &Route{Name: "daily", ChatID: -100, Digest: &Digest{Every: time.Hour}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		if !vtCli.stateMu.TryLock() {
+			locked = true
+		} else {
+			vtCli.stateMu.Unlock()
+
+			if !added {
+				added = true
+
+				_ = vtCli.addToDigest(route, &vkObject.WallWallpost{ID: 3, OwnerID: -5, Text: "Lost parrot"})
+			}
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"ok":true,"result":{"message_id":9,"chat":{"id":-100}}}`))
+	}))
+	defer server.Close()
+
+	bot, err := tb.NewBot(tb.Settings{Token: "token", URL: server.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = route.Digest.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli = NewVTClient("", "", 0, 1)
+	vtCli.tgClient = bot
+	vtCli.routes = []*Route{route}
+
+	data, err := json.Marshal(digestBatch{
+		Since: time.Now().Add(-2 * time.Hour),
+		Items: []digestItem{{PostID: 1, URL: "https://vk.com/wall-5_1", Summary: "Lost cat"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vtCli.storage.Set(digestKey(route), data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli.sendDigests()
+
+	if locked {
+		t.Error("expected the digest to be sent without the state lock")
+	}
+
+	batch, err := vtCli.loadDigest(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if batch == nil || len(batch.Items) != 1 || batch.Items[0].PostID != 3 {
+		t.Errorf("expected the post added while sending to stay, got %+v", batch)
+	}
+}
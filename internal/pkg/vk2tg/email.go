@@ -0,0 +1,171 @@
+package vk2tg
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// emailSubjectLength is the max length of the post summary in the subject.
+	emailSubjectLength = 60
+	// smtpPermanentCode is the first SMTP reply code of permanent failures.
+	smtpPermanentCode = 500
+)
+
+// EmailSink mails posts through an SMTP server, using STARTTLS when the server offers it.
+type EmailSink struct {
+	Addr     string   `yaml:"addr"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+func (sink *EmailSink) init() error {
+	if sink.From == "" || len(sink.To) == 0 {
+		return errors.New("email: from and to are required")
+	}
+
+	_, _, err := net.SplitHostPort(sink.Addr)
+	if err != nil {
+		return errors.Wrap(err, "email: invalid server address")
+	}
+
+	return nil
+}
+
+func (sink *EmailSink) Send(ctx context.Context, message *outgoing) error {
+	host, _, _ := net.SplitHostPort(sink.Addr)
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", sink.Addr)
+	if err != nil {
+		return errors.Wrap(err, "can't connect to SMTP server")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			conn.Close()
+
+			return errors.Wrap(err, "can't set deadline")
+		}
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+
+		return errors.Wrap(err, "SMTP handshake failed")
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err != nil {
+			return errors.Wrap(err, "STARTTLS failed")
+		}
+	}
+
+	if sink.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", sink.Username, sink.Password, host))
+		if err != nil {
+			return errors.Mark(errors.Wrap(err, "SMTP authentication failed"), errPermanent)
+		}
+	}
+
+	err = sink.transmit(client, sink.compose(message))
+	if err != nil {
+		return err
+	}
+
+	// The server has accepted the mail, a failed goodbye is not worth sending it again.
+	_ = client.Quit()
+
+	return nil
+}
+
+func (sink *EmailSink) transmit(client *smtp.Client, mail []byte) error {
+	err := client.Mail(sink.From)
+	if err != nil {
+		return smtpRejected(errors.Wrap(err, "sender rejected"))
+	}
+
+	for _, recipient := range sink.To {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return smtpRejected(errors.Wrapf(err, "recipient %s rejected", recipient))
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return smtpRejected(errors.Wrap(err, "SMTP data failed"))
+	}
+
+	_, err = writer.Write(mail)
+	if err != nil {
+		return errors.Mark(errors.Wrap(err, "can't write mail"), errMaybeDelivered)
+	}
+
+	err = writer.Close()
+
+	// A reply means the server refused the mail, while a lost connection leaves it unknown.
+	var reply *textproto.Error
+	if err != nil && !errors.As(err, &reply) {
+		return errors.Mark(errors.Wrap(err, "can't finish mail"), errMaybeDelivered)
+	}
+
+	return smtpRejected(errors.Wrap(err, "mail rejected"))
+}
+
+// smtpRejected marks an error carrying a 5xx reply as permanent, 4xx replies are worth retrying.
+func smtpRejected(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= smtpPermanentCode {
+		return errors.Mark(err, errPermanent)
+	}
+
+	return err
+}
+
+// compose builds a plain text UTF-8 mail with links to the post and its photos.
+func (sink *EmailSink) compose(message *outgoing) []byte {
+	var body bytes.Buffer
+
+	encoder := quotedprintable.NewWriter(&body)
+
+	_, _ = encoder.Write([]byte(message.text + "\n\n" + message.view.URL + "\n"))
+	for _, photo := range message.photos {
+		_, _ = encoder.Write([]byte(photo + "\n"))
+	}
+
+	_ = encoder.Close()
+
+	subject := message.view.Source + ": " + summarize(message.text, emailSubjectLength)
+
+	var mail bytes.Buffer
+
+	fmt.Fprintf(&mail, "From: %s\r\n", sink.From)
+	fmt.Fprintf(&mail, "To: %s\r\n", strings.Join(sink.To, ", "))
+	fmt.Fprintf(&mail, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&mail, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	mail.WriteString("MIME-Version: 1.0\r\n")
+	mail.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	mail.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	mail.Write(body.Bytes())
+
+	return mail.Bytes()
+}
@@ -0,0 +1,292 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// thumbnailSize is the longer side of the photo thumbnails of the HTML export.
+	thumbnailSize = 320
+	// thumbnailDir is the directory of the thumbnails within the HTML export.
+	thumbnailDir = "thumbs"
+	// supergroupOffset is subtracted from supergroup and channel IDs in t.me/c/ links.
+	supergroupOffset = -1_000_000_000_000
+)
+
+// ArchiveSize is how many forwarded posts the archive keeps, older ones can't be exported.
+const ArchiveSize = archiveSize
+
+// ExportZone returns the timezone the HTML export groups days in.
+func ExportZone() *time.Location {
+	return zone
+}
+
+// exportedPost is an archived post as written by the export.
+type exportedPost struct {
+	Ref string `json:"ref"`
+	URL string `json:"url"`
+
+	*archivedPost
+}
+
+// exportDay is a page of the HTML export.
+type exportDay struct {
+	Date  string
+	Prev  string
+	Next  string
+	Posts []exportedHTMLPost
+}
+
+// exportedHTMLPost adds what the HTML page shows to an exported post.
+type exportedHTMLPost struct {
+	*exportedPost
+
+	Time       string
+	Thumbnails []exportedThumbnail
+	Targets    []exportedTarget
+}
+
+type exportedThumbnail struct {
+	Src  string
+	Link string
+}
+
+// exportedTarget is a delivery as shown on the page, Link is empty for chats without public message links.
+// Deliveries to sinks have a Sink name instead of a chat.
+type exportedTarget struct {
+	Route string
+	Sink  string
+	Chat  int64
+	Link  string
+}
+
+var exportIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>vk2tg archive</title>` + exportStyle + `</head>
+<body><h1>vk2tg archive</h1>
+<ul>{{ range . }}<li><a href="{{ .Date }}.html">{{ .Date }}</a> — {{ len .Posts }}</li>
+{{ end }}</ul>
+</body></html>
+`))
+
+var exportDayTemplate = template.Must(template.New("day").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>vk2tg archive: {{ .Date }}</title>` + exportStyle + `</head>
+<body>
+<nav>{{ with .Prev }}<a href="{{ . }}.html">← {{ . }}</a> {{ end }}<a href="index.html">index</a>{{ with .Next }} <a href="{{ . }}.html">{{ . }} →</a>{{ end }}</nav>
+<h1>{{ .Date }}</h1>
+{{ range .Posts }}<article>
+<header>{{ .Time }} · {{ .Source }} · <a href="{{ .URL }}">{{ .Ref }}</a></header>
+<p>{{ .Text }}</p>
+{{ range .Thumbnails }}<a href="{{ .Link }}"><img src="{{ .Src }}" alt=""></a>{{ end }}
+<ul>{{ range .Targets }}<li>{{ .Route }}: {{ if .Sink }}{{ .Sink }}{{ else if .Link }}<a href="{{ .Link }}">{{ .Chat }}</a>{{ else }}{{ .Chat }}{{ end }}</li>{{ end }}</ul>
+</article>
+{{ end }}</body></html>
+`))
+
+const exportStyle = `<style>
+body { font-family: sans-serif; max-width: 50em; margin: auto; padding: 1em; }
+article { border-top: 1px solid #ccc; padding: 0.5em 0; }
+header { color: #666; }
+p { white-space: pre-wrap; }
+img { max-height: 160px; margin-right: 0.5em; }
+</style>`
+
+// exportedPosts returns the archived posts forwarded since the time, oldest first.
+func (vtCli *VTClinent) exportedPosts(since time.Time) ([]*exportedPost, error) {
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]*exportedPost, 0, len(posts))
+
+	for _, post := range slices.Backward(posts) {
+		if post.Forwarded.Before(since) {
+			continue
+		}
+
+		exported = append(exported, &exportedPost{
+			Ref:          strconv.Itoa(post.OwnerID) + "_" + strconv.Itoa(post.PostID),
+			URL:          post.url(),
+			archivedPost: post,
+		})
+	}
+
+	return exported, nil
+}
+
+// ExportJSONL writes the archived posts forwarded since the time as JSON lines, oldest first.
+// It returns the number of posts written.
+func (vtCli *VTClinent) ExportJSONL(out io.Writer, since time.Time) (int, error) {
+	posts, err := vtCli.exportedPosts(since)
+	if err != nil {
+		return 0, err
+	}
+
+	encoder := json.NewEncoder(out)
+
+	for index, post := range posts {
+		err = encoder.Encode(post)
+		if err != nil {
+			return index, errors.Wrap(err, "can't write post")
+		}
+	}
+
+	return len(posts), nil
+}
+
+// ExportHTML renders the archived posts forwarded since the time as static pages in dir:
+// an index and a page per day with local thumbnails of the photos.
+// Thumbnails already in dir are reused, so the export can be refreshed.
+// It returns the number of posts written.
+func (vtCli *VTClinent) ExportHTML(dir string, since time.Time) (int, error) {
+	posts, err := vtCli.exportedPosts(since)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.MkdirAll(filepath.Join(dir, thumbnailDir), 0o755) //nolint:mnd // rwxr-xr-x
+	if err != nil {
+		return 0, errors.Wrap(err, "can't create export dir")
+	}
+
+	var days []*exportDay
+
+	for _, post := range posts {
+		date := post.Forwarded.In(zone).Format(time.DateOnly)
+
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, &exportDay{Date: date})
+		}
+
+		day := days[len(days)-1]
+		day.Posts = append(day.Posts, vtCli.exportedHTMLPost(dir, post))
+	}
+
+	for index, day := range days {
+		if index > 0 {
+			day.Prev = days[index-1].Date
+		}
+
+		if index < len(days)-1 {
+			day.Next = days[index+1].Date
+		}
+
+		err = writeTemplate(filepath.Join(dir, day.Date+".html"), exportDayTemplate, day)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = writeTemplate(filepath.Join(dir, "index.html"), exportIndexTemplate, days)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(posts), nil
+}
+
+func (vtCli *VTClinent) exportedHTMLPost(dir string, post *exportedPost) exportedHTMLPost {
+	result := exportedHTMLPost{
+		exportedPost: post,
+		Time:         post.Forwarded.In(zone).Format("15:04"),
+	}
+
+	for _, url := range post.Media {
+		src, err := vtCli.thumbnail(dir, url)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't make thumbnail of %s: %s", post.PostID, url, err)
+
+			continue
+		}
+
+		result.Thumbnails = append(result.Thumbnails, exportedThumbnail{Src: src, Link: url})
+	}
+
+	for _, delivery := range post.Deliveries {
+		result.Targets = append(result.Targets, exportedTarget{
+			Route: delivery.Route,
+			Sink:  delivery.Sink,
+			Chat:  delivery.ChatID,
+			Link:  messageLink(delivery),
+		})
+	}
+
+	return result
+}
+
+// thumbnail downloads the photo into the thumbnails of dir unless it is there and returns its relative path.
+func (vtCli *VTClinent) thumbnail(dir, url string) (string, error) {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(url))
+
+	name := filepath.Join(thumbnailDir, strconv.FormatUint(hasher.Sum64(), 16)+".jpg")
+	target := filepath.Join(dir, name)
+
+	if _, err := os.Stat(target); err == nil {
+		return filepath.ToSlash(name), nil
+	}
+
+	media := vtCli.media
+	if media == nil {
+		media = fallbackMedia
+	}
+
+	path, release, err := media.download(url)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	err = (&Images{MaxDimension: thumbnailSize, Quality: defaultJPEGQuality}).apply(path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read thumbnail")
+	}
+
+	err = os.WriteFile(target, data, 0o644) //nolint:mnd,gosec // rw-r--r--, the export is meant to be shared
+	if err != nil {
+		return "", errors.Wrap(err, "can't save thumbnail")
+	}
+
+	return filepath.ToSlash(name), nil
+}
+
+// messageLink links to a message in a supergroup or channel, other chats have no such links.
+func messageLink(delivery sentMessage) string {
+	if delivery.ChatID > supergroupOffset {
+		return ""
+	}
+
+	return "https://t.me/c/" + strconv.FormatInt(supergroupOffset-delivery.ChatID, 10) + "/" + strconv.Itoa(delivery.MessageID)
+}
+
+func writeTemplate(path string, tmpl *template.Template, data any) error {
+	var builder strings.Builder
+
+	err := tmpl.Execute(&builder, data)
+	if err != nil {
+		return errors.Wrapf(err, "can't render %s", filepath.Base(path))
+	}
+
+	err = os.WriteFile(path, []byte(builder.String()), 0o644) //nolint:mnd,gosec // rw-r--r--, the export is meant to be shared
+	if err != nil {
+		return errors.Wrapf(err, "can't write %s", filepath.Base(path))
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package vk2tg
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+)
+
+// TestExport tests that both formats cover posts forwarded since the time, oldest first.
+func TestExport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_ = png.Encode(writer, image.NewRGBA(image.Rect(0, 0, 640, 480)))
+	}))
+	defer server.Close()
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.sources = []Source{&VKSource{Name: "poisk", OwnerID: -5}}
+
+	photo := vkObject.WallWallpostAttachment{Type: "photo", Photo: vkObject.PhotosPhoto{
+		Sizes: []vkObject.PhotosPhotoSizes{{BaseImage: vkObject.BaseImage{URL: server.URL + "/cat.png", Width: 640, Height: 480}}},
+	}}
+
+	vtCli.archivePost(&vkObject.WallWallpost{ID: 1, OwnerID: -5, Text: "old"}, nil)
+
+	since := time.Now()
+
+	vtCli.archivePost(
+		&vkObject.WallWallpost{ID: 2, OwnerID: -5, Text: "<lost> cat", Attachments: []vkObject.WallWallpostAttachment{photo}},
+		[]sentMessage{{Route: "search", ChatID: -1001234567890, MessageID: 40}},
+	)
+	vtCli.archivePost(&vkObject.WallWallpost{ID: 3, OwnerID: -5, Text: "found dog"}, []sentMessage{{Route: "search", ChatID: 42, MessageID: 7}, {Route: "search", Sink: "partners"}})
+
+	var out bytes.Buffer
+
+	count, err := vtCli.ExportJSONL(&out, since)
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 posts, got %d, %v", count, err)
+	}
+
+	first := exportedPost{archivedPost: new(archivedPost)}
+
+	err = json.Unmarshal([]byte(strings.SplitN(out.String(), "\n", 2)[0]), &first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Ref != "-5_2" || first.Source != "poisk" || len(first.Media) != 1 || first.Deliveries[0].Route != "search" {
+		t.Errorf("unexpected first post %+v", first.archivedPost)
+	}
+
+	dir := t.TempDir()
+
+	count, err = vtCli.ExportHTML(dir, since)
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 posts, got %d, %v", count, err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, time.Now().In(zone).Format(time.DateOnly)+".html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"&lt;lost&gt; cat", "found dog", `src="thumbs/`, "https://t.me/c/1234567890/40", "search: partners"} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("%q is missing from the page", expected)
+		}
+	}
+
+	thumbs, err := filepath.Glob(filepath.Join(dir, thumbnailDir, "*.jpg"))
+	if err != nil || len(thumbs) != 1 {
+		t.Errorf("expected a thumbnail, got %v, %v", thumbs, err)
+	}
+}
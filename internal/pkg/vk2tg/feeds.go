@@ -0,0 +1,506 @@
+package vk2tg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"hash/fnv"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	// feedOwnerBase is the first synthetic owner ID of feeds, far below real VK community IDs.
+	feedOwnerBase = -2_000_000_000
+	// feedOwnerRange is how many synthetic owner IDs there are, they still fit in 32 bits.
+	feedOwnerRange = 100_000_000
+	// feedFetchTimeout limits a single feed request.
+	feedFetchTimeout = 30 * time.Second
+	// feedMaxBytes caps the size of a feed document.
+	feedMaxBytes = 10 << 20
+	// feedItemTTL is how long GUIDs of seen items are remembered, longer than items stay in feeds.
+	feedItemTTL = 90 * 24 * time.Hour
+)
+
+var (
+	htmlBreakPattern     = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</li>`)
+	htmlParagraphPattern = regexp.MustCompile(`(?i)</p>`)
+	htmlTagPattern       = regexp.MustCompile(`<[^>]*>`)
+	blankLinePattern     = regexp.MustCompile(`\n\s*\n\s*`)
+)
+
+// feedDateLayouts are the date formats met in RSS and Atom feeds.
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// FeedSource is an RSS 2.0 or Atom feed whose items are forwarded like VK posts.
+type FeedSource struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	ownerID  int
+	position feedState
+	health   sourceHealth
+
+	// Validators of the last response and GUIDs of its new items,
+	// committed once every item is marked as seen
+	pending *feedState
+	guids   map[int]string
+}
+
+// feedState is the position of a feed as persisted in storage.
+type feedState struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	// Primed is set after the first fetch, which marks existing items seen without forwarding them.
+	Primed bool `json:"primed"`
+}
+
+// feedDocument matches both RSS 2.0 and Atom documents.
+type feedDocument struct {
+	Items   []rssItem   `xml:"channel>item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Enclosures  []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+}
+
+// feedItem is an RSS item or Atom entry in a common form.
+type feedItem struct {
+	guid   string
+	title  string
+	link   string
+	text   string
+	date   time.Time
+	images []string
+}
+
+func (source *FeedSource) init() error {
+	if source.Name == "" || source.URL == "" {
+		return errors.New("name and URL are required")
+	}
+
+	err := validateURL("feed", source.URL)
+	if err != nil {
+		return err
+	}
+
+	source.ownerID = feedOwnerID(source.Name)
+
+	return nil
+}
+
+// feedOwnerID derives the synthetic owner ID of a feed from its name, like its storage keys,
+// so archived and queued items keep pointing at the feed when the config is reordered.
+func feedOwnerID(name string) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+
+	return feedOwnerBase - int(hash.Sum32()%feedOwnerRange)
+}
+
+// isFeedOwner reports whether the owner ID is a synthetic one of a feed.
+func isFeedOwner(ownerID int) bool {
+	return ownerID <= feedOwnerBase
+}
+
+func feedKey(name string) string {
+	return "feed:" + name
+}
+
+func feedItemKey(name, guid string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(guid))
+
+	return "feed-item:" + name + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func (source *FeedSource) name() string {
+	return source.Name
+}
+
+func (source *FeedSource) owner() int {
+	return source.ownerID
+}
+
+func (source *FeedSource) state() *sourceHealth {
+	return &source.health
+}
+
+func (source *FeedSource) load(store storage) error {
+	data, err := store.Get(feedKey(source.Name))
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, &source.position)
+	if err != nil {
+		return errors.Wrap(err, "can't decode feed state")
+	}
+
+	return nil
+}
+
+func (source *FeedSource) fetch(vtCli *VTClinent) ([]*vkObject.WallWallpost, error) {
+	items, validators, err := source.request()
+	if err != nil {
+		return nil, err
+	}
+
+	if items == nil {
+		return nil, nil
+	}
+
+	source.pending = validators
+	source.guids = make(map[int]string)
+
+	var posts []*vkObject.WallWallpost
+
+	for _, item := range items {
+		_, err = vtCli.storage.Get(feedItemKey(source.Name, item.guid))
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, errNotFound) {
+			return nil, err
+		}
+
+		post := source.wallPost(item)
+		source.guids[post.ID] = item.guid
+
+		posts = append(posts, post)
+	}
+
+	if !source.position.Primed {
+		vtCli.logger.Printf("Source %s: First fetch, %d items marked as seen", source.Name, len(posts))
+
+		for _, post := range posts {
+			err = source.markSeen(vtCli.storage, post)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, source.commit(vtCli.storage)
+	}
+
+	if len(posts) == 0 {
+		return nil, source.commit(vtCli.storage)
+	}
+
+	return posts, nil
+}
+
+func (source *FeedSource) markSeen(store storage, post *vkObject.WallWallpost) error {
+	guid, ok := source.guids[post.ID]
+	if !ok {
+		return errors.Newf("unknown feed item %d", post.ID)
+	}
+
+	err := store.Set(feedItemKey(source.Name, guid), []byte(post.Copyright.Link), feedItemTTL)
+	if err != nil {
+		return err
+	}
+
+	delete(source.guids, post.ID)
+
+	if len(source.guids) == 0 {
+		return source.commit(store)
+	}
+
+	return nil
+}
+
+// commit saves the validators of the last response, so the next request may be answered with 304.
+func (source *FeedSource) commit(store storage) error {
+	if source.pending == nil {
+		return nil
+	}
+
+	state := *source.pending
+	state.Primed = true
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "can't encode feed state")
+	}
+
+	err = store.Set(feedKey(source.Name), data, 0)
+	if err != nil {
+		return err
+	}
+
+	source.position = state
+	source.pending = nil
+
+	return nil
+}
+
+// request fetches the feed, returning nil items when it hasn't changed since the last fetch.
+func (source *FeedSource) request() ([]feedItem, *feedState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), feedFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, http.NoBody)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't create request")
+	}
+
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	if source.position.ETag != "" {
+		req.Header.Set("If-None-Match", source.position.ETag)
+	}
+
+	if source.position.LastModified != "" {
+		req.Header.Set("If-Modified-Since", source.position.LastModified)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to fetch feed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, errors.Newf("failed to fetch feed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, feedMaxBytes))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read feed")
+	}
+
+	items, err := parseFeed(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return items, &feedState{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// feedCharsets are the legacy encodings feeds still declare, by lowercase label.
+var feedCharsets = map[string]*charmap.Charmap{
+	"windows-1251": charmap.Windows1251,
+	"cp1251":       charmap.Windows1251,
+	"koi8-r":       charmap.KOI8R,
+	"koi8-u":       charmap.KOI8U,
+	"iso-8859-5":   charmap.ISO8859_5,
+	"windows-1252": charmap.Windows1252,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+	// Latin-1 is a superset of ASCII.
+	"us-ascii": charmap.ISO8859_1,
+}
+
+// feedCharsetReader decodes a feed declared in a legacy encoding, encoding/xml only reads UTF-8.
+func feedCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	encoding, ok := feedCharsets[strings.ToLower(strings.TrimSpace(label))]
+	if !ok {
+		return nil, errors.Newf("unsupported feed encoding %q", label)
+	}
+
+	return encoding.NewDecoder().Reader(input), nil
+}
+
+// parseFeed returns the items of an RSS or Atom document, oldest first.
+func parseFeed(data []byte) ([]feedItem, error) {
+	var document feedDocument
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = feedCharsetReader
+
+	err := decoder.Decode(&document)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse feed")
+	}
+
+	items := make([]feedItem, 0, len(document.Items)+len(document.Entries))
+
+	for index := range document.Items {
+		items = append(items, document.Items[index].item())
+	}
+
+	for index := range document.Entries {
+		items = append(items, document.Entries[index].item())
+	}
+
+	items = slices.DeleteFunc(items, func(item feedItem) bool { return item.guid == "" })
+
+	// Feeds list items newest first, sorting keeps the original order for items without dates.
+	slices.Reverse(items)
+	slices.SortStableFunc(items, func(first, second feedItem) int { return first.date.Compare(second.date) })
+
+	return items, nil
+}
+
+func (rss *rssItem) item() feedItem {
+	item := feedItem{
+		guid:  firstNonEmpty(rss.GUID, rss.Link, rss.Title),
+		title: strings.TrimSpace(rss.Title),
+		link:  strings.TrimSpace(rss.Link),
+		text:  stripHTML(rss.Description),
+		date:  parseFeedDate(rss.PubDate),
+	}
+
+	for _, enclosure := range rss.Enclosures {
+		if strings.HasPrefix(enclosure.Type, "image/") {
+			item.images = append(item.images, enclosure.URL)
+		}
+	}
+
+	return item
+}
+
+func (atom *atomEntry) item() feedItem {
+	item := feedItem{
+		title: strings.TrimSpace(atom.Title),
+		text:  stripHTML(firstNonEmpty(atom.Content, atom.Summary)),
+		date:  parseFeedDate(firstNonEmpty(atom.Published, atom.Updated)),
+	}
+
+	for _, link := range atom.Links {
+		switch {
+		case link.Rel == "enclosure" && strings.HasPrefix(link.Type, "image/"):
+			item.images = append(item.images, link.Href)
+		case (link.Rel == "" || link.Rel == "alternate") && item.link == "":
+			item.link = strings.TrimSpace(link.Href)
+		}
+	}
+
+	item.guid = firstNonEmpty(atom.ID, item.link, item.title)
+
+	return item
+}
+
+// wallPost converts the item, so it goes through the same filters and templates as VK posts.
+func (source *FeedSource) wallPost(item feedItem) *vkObject.WallWallpost {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(item.guid))
+
+	text := item.text
+	if item.title != "" && !strings.HasPrefix(text, item.title) {
+		text = strings.TrimSpace(item.title + "\n\n" + text)
+	}
+
+	post := &vkObject.WallWallpost{
+		ID:        int(hash.Sum32() & 0x7fffffff), //nolint:mnd // positive 31 bits
+		OwnerID:   source.ownerID,
+		FromID:    source.ownerID,
+		Text:      text,
+		Date:      int(item.date.Unix()),
+		Copyright: vkObject.WallPostCopyright{Link: item.link, Name: source.Name},
+	}
+
+	for _, url := range item.images {
+		// Photos are cached by owner and ID, so each image needs an ID of its own.
+		imageHash := fnv.New32a()
+		_, _ = imageHash.Write([]byte(url))
+
+		post.Attachments = append(post.Attachments, vkObject.WallWallpostAttachment{
+			Type: "photo",
+			Photo: vkObject.PhotosPhoto{
+				ID:      int(imageHash.Sum32() & 0x7fffffff), //nolint:mnd // positive 31 bits
+				OwnerID: source.ownerID,
+				Sizes:   []vkObject.PhotosPhotoSizes{{BaseImage: vkObject.BaseImage{URL: url, Width: 1, Height: 1}}},
+			},
+		})
+	}
+
+	return post
+}
+
+// stripHTML turns an HTML fragment into plain text, keeping paragraphs apart.
+func stripHTML(fragment string) string {
+	text := htmlParagraphPattern.ReplaceAllString(fragment, "\n\n")
+	text = htmlBreakPattern.ReplaceAllString(text, "\n")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = blankLinePattern.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text)
+}
+
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range feedDateLayouts {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date
+		}
+	}
+
+	return time.Now()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// feedProfile stands in for a VK profile of a feed in templates.
+func (vtCli *VTClinent) feedProfile(ownerID int) profile {
+	result := profile{ID: ownerID, Name: vtCli.sourceName(ownerID)}
+
+	if feed, ok := vtCli.source(ownerID).(*FeedSource); ok {
+		result.URL = feed.URL
+	}
+
+	return result
+}
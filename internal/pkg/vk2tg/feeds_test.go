@@ -0,0 +1,222 @@
+package vk2tg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>News</title>
+%s
+<item><title>Old</title><link>https://example.com/old</link><guid>old</guid>
+<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate><description>Old news</description></item>
+</channel></rss>`
+
+const testNewItem = `<item><title>Lost cat</title><link>https://example.com/cat</link><guid>cat</guid>
+<pubDate>Tue, 03 Jan 2006 15:04:05 +0000</pubDate><description>&lt;p&gt;Seen &amp;amp; lost&lt;/p&gt;&lt;p&gt;#поиск&lt;/p&gt;</description>
+<enclosure url="https://example.com/cat.jpg" type="image/jpeg" length="1"/></item>`
+
+// TestParseFeed tests that RSS and Atom items are read oldest first with plain text.
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		guids    []string
+		text     string
+		link     string
+	}{
+		{
+			name:     "rss",
+			document: strings.Replace(testRSS, "%s", testNewItem, 1),
+			guids:    []string{"old", "cat"},
+			text:     "Seen & lost\n\n#поиск",
+			link:     "https://example.com/cat",
+		},
+		{
+			name: "atom",
+			document: `<feed xmlns="http://www.w3.org/2005/Atom">
+<entry><id>tag:b</id><title>B</title><link rel="alternate" href="https://example.com/b"/>
+<updated>2024-01-02T00:00:00Z</updated><summary>Second</summary></entry>
+<entry><id>tag:a</id><title>A</title><link href="https://example.com/a"/>
+<updated>2024-01-01T00:00:00Z</updated><content type="html">First</content></entry>
+</feed>`,
+			guids: []string{"tag:a", "tag:b"},
+			text:  "Second",
+			link:  "https://example.com/b",
+		},
+		{
+			name: "rss in windows-1251",
+			document: windows1251(t, `<?xml version="1.0" encoding="windows-1251"?><rss><channel>
+<item><guid>dog</guid><link>https://example.com/dog</link><description>Пропала собака</description></item>
+</channel></rss>`),
+			guids: []string{"dog"},
+			text:  "Пропала собака",
+			link:  "https://example.com/dog",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			items, err := parseFeed([]byte(testCase.document))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(items) != len(testCase.guids) {
+				t.Fatalf("expected %d items, got %d", len(testCase.guids), len(items))
+			}
+
+			for index, guid := range testCase.guids {
+				if items[index].guid != guid {
+					t.Errorf("item %d: expected GUID %q, got %q", index, guid, items[index].guid)
+				}
+			}
+
+			last := items[len(items)-1]
+			if last.text != testCase.text || last.link != testCase.link {
+				t.Errorf("unexpected last item %q at %q", last.text, last.link)
+			}
+		})
+	}
+}
+
+// TestFeedFetch tests priming, GUID dedup and conditional requests against a stand-in server.
+func TestFeedFetch(t *testing.T) {
+	var (
+		items       atomic.Value
+		conditional atomic.Int32
+	)
+
+	items.Store("")
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		document := strings.Replace(testRSS, "%s", items.Load().(string), 1) //nolint:forcetypeassert // test
+		etag := `"` + strconv.Itoa(len(document)) + `"`
+
+		if req.Header.Get("If-None-Match") == etag {
+			conditional.Add(1)
+			writer.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		writer.Header().Set("ETag", etag)
+		_, _ = writer.Write([]byte(document))
+	}))
+	defer server.Close()
+
+	vtCli := NewVTClient("", "", 0, 1)
+	feed := &FeedSource{Name: "news", URL: server.URL}
+
+	err := feed.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli.sources = []Source{feed}
+
+	posts, err := feed.fetch(vtCli)
+	if err != nil || len(posts) != 0 {
+		t.Fatalf("first fetch should only mark items as seen, got %d posts, %v", len(posts), err)
+	}
+
+	posts, err = feed.fetch(vtCli)
+	if err != nil || len(posts) != 0 || conditional.Load() != 1 {
+		t.Fatalf("unchanged feed should be answered with 304, got %d posts, %v", len(posts), err)
+	}
+
+	items.Store(testNewItem)
+
+	posts, err = feed.fetch(vtCli)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("expected the new item, got %d posts, %v", len(posts), err)
+	}
+
+	post := posts[0]
+	if !isFeedOwner(post.OwnerID) || postURL(post) != "https://example.com/cat" || len(post.Attachments) != 1 {
+		t.Errorf("unexpected post %+v", post)
+	}
+
+	if !strings.HasPrefix(post.Text, "Lost cat\n\n") {
+		t.Errorf("title is missing from %q", post.Text)
+	}
+
+	err = feed.markSeen(vtCli.storage, post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	posts, err = feed.fetch(vtCli)
+	if err != nil || len(posts) != 0 || conditional.Load() != 2 {
+		t.Fatalf("seen items should not repeat, got %d posts, %v", len(posts), err)
+	}
+}
+
+// TestFeedPhotoKeys tests that images of feed items don't share a file ID cache key.
+func TestFeedPhotoKeys(t *testing.T) {
+	feed := &FeedSource{Name: "news", URL: "https://example.com/feed"}
+
+	err := feed.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := feed.wallPost(feedItem{guid: "1", images: []string{"https://example.com/a.jpg", "https://example.com/b.jpg"}})
+	second := feed.wallPost(feedItem{guid: "2", images: []string{"https://example.com/c.jpg"}})
+
+	keys := map[string]bool{}
+
+	for _, post := range []*vkObject.WallWallpost{first, second} {
+		for index := range post.Attachments {
+			if post.Attachments[index].Photo.ID == 0 {
+				t.Errorf("expected a photo ID for %s", photoURL(&post.Attachments[index].Photo))
+			}
+
+			keys[photoKey(&post.Attachments[index].Photo)] = true
+		}
+	}
+
+	if len(keys) != 3 {
+		t.Errorf("expected 3 distinct keys, got %v", keys)
+	}
+}
+
+func windows1251(t *testing.T, text string) string {
+	t.Helper()
+
+	encoded, err := charmap.Windows1251.NewEncoder().String(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return encoded
+}
+
+// TestFeedOwnerID tests that feed owner IDs only depend on the feed name.
+func TestFeedOwnerID(t *testing.T) {
+	first := &FeedSource{Name: "news", URL: "https://example.com/news.xml"}
+	moved := &FeedSource{Name: "news", URL: "https://example.org/rss"}
+	other := &FeedSource{Name: "lost-pets", URL: "https://example.com/news.xml"}
+
+	for _, feed := range []*FeedSource{first, moved, other} {
+		err := feed.init()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !isFeedOwner(feed.ownerID) || feed.ownerID <= feedOwnerBase-feedOwnerRange {
+			t.Errorf("feed %s: owner ID %d is out of the feed range", feed.Name, feed.ownerID)
+		}
+	}
+
+	if first.ownerID != moved.ownerID || first.ownerID == other.ownerID {
+		t.Errorf("expected the owner ID to follow the name, got %d, %d and %d", first.ownerID, moved.ownerID, other.ownerID)
+	}
+}
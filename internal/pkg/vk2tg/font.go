@@ -0,0 +1,128 @@
+package vk2tg
+
+import (
+	"slices"
+	"unicode"
+)
+
+// Size of a glyph of the built-in watermark font.
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// glyphs is a tiny bitmap font for text watermarks: Latin and Cyrillic capitals, digits and common punctuation.
+// Lower case letters are drawn as capitals and anything else as a question mark.
+var glyphs = map[rune][glyphHeight]string{
+	' ':  {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'!':  {"..#..", "..#..", "..#..", "..#..", "..#..", ".....", "..#.."},
+	'"':  {".#.#.", ".#.#.", ".#.#.", ".....", ".....", ".....", "....."},
+	'#':  {".#.#.", ".#.#.", "#####", ".#.#.", "#####", ".#.#.", ".#.#."},
+	'\'': {"..#..", "..#..", ".#...", ".....", ".....", ".....", "....."},
+	'(':  {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')':  {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'*':  {".....", "#.#.#", ".###.", "#####", ".###.", "#.#.#", "....."},
+	'+':  {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	',':  {".....", ".....", ".....", ".....", ".##..", "..#..", ".#..."},
+	'-':  {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'.':  {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	'/':  {".....", "....#", "...#.", "..#..", ".#...", "#....", "....."},
+	'0':  {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1':  {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2':  {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3':  {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4':  {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5':  {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6':  {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7':  {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8':  {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9':  {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	':':  {".....", ".##..", ".##..", ".....", ".##..", ".##..", "....."},
+	'=':  {".....", ".....", "#####", ".....", "#####", ".....", "....."},
+	'?':  {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+	'@':  {".###.", "#...#", "....#", ".##.#", "#.#.#", "#.#.#", ".###."},
+	'A':  {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B':  {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C':  {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D':  {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E':  {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F':  {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G':  {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".###."},
+	'H':  {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I':  {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J':  {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K':  {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L':  {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M':  {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N':  {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O':  {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P':  {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q':  {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R':  {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S':  {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T':  {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U':  {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V':  {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W':  {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X':  {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y':  {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z':  {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'_':  {".....", ".....", ".....", ".....", ".....", ".....", "#####"},
+	'|':  {"..#..", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	// Cyrillic capitals, those looking like Latin ones are drawn the same.
+	'Ё': {".#.#.", ".....", "#####", "#....", "####.", "#....", "#####"},
+	'А': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'Б': {"#####", "#....", "#....", "####.", "#...#", "#...#", "####."},
+	'В': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'Г': {"#####", "#....", "#....", "#....", "#....", "#....", "#...."},
+	'Д': {"..##.", ".#.#.", ".#.#.", ".#.#.", ".#.#.", "#####", "#...#"},
+	'Е': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'Ж': {"#.#.#", "#.#.#", ".###.", "..#..", ".###.", "#.#.#", "#.#.#"},
+	'З': {".###.", "#...#", "....#", "..##.", "....#", "#...#", ".###."},
+	'И': {"#...#", "#...#", "#..##", "#.#.#", "##..#", "#...#", "#...#"},
+	'Й': {".#.#.", "..#..", "#...#", "#..##", "#.#.#", "##..#", "#...#"},
+	'К': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'Л': {"..###", ".#..#", ".#..#", ".#..#", ".#..#", ".#..#", "#...#"},
+	'М': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'Н': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'О': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'П': {"#####", "#...#", "#...#", "#...#", "#...#", "#...#", "#...#"},
+	'Р': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'С': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'Т': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'У': {"#...#", "#...#", "#...#", ".####", "....#", "#...#", ".###."},
+	'Ф': {"..#..", ".###.", "#.#.#", "#.#.#", "#.#.#", ".###.", "..#.."},
+	'Х': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Ц': {"#..#.", "#..#.", "#..#.", "#..#.", "#..#.", "#####", "....#"},
+	'Ч': {"#...#", "#...#", "#...#", ".####", "....#", "....#", "....#"},
+	'Ш': {"#.#.#", "#.#.#", "#.#.#", "#.#.#", "#.#.#", "#.#.#", "#####"},
+	'Щ': {"#.#.#", "#.#.#", "#.#.#", "#.#.#", "#.#.#", "#####", "....#"},
+	'Ъ': {"##...", ".#...", ".#...", ".###.", ".#..#", ".#..#", ".###."},
+	'Ы': {"#...#", "#...#", "#...#", "##..#", "#.#.#", "#.#.#", "##..#"},
+	'Ь': {"#....", "#....", "#....", "####.", "#...#", "#...#", "####."},
+	'Э': {".###.", "#...#", "....#", "..###", "....#", "#...#", ".###."},
+	'Ю': {"#..#.", "#.#.#", "#.#.#", "###.#", "#.#.#", "#.#.#", "#..#."},
+	'Я': {".####", "#...#", "#...#", ".####", "..#.#", ".#..#", "#...#"},
+}
+
+// glyph returns the bitmap of the rune in the built-in font.
+func glyph(char rune) [glyphHeight]string {
+	if bitmap, ok := glyphs[unicode.ToUpper(char)]; ok {
+		return bitmap
+	}
+
+	return glyphs['?']
+}
+
+// missingGlyphs returns the distinct runes of text the built-in font can't draw.
+func missingGlyphs(text string) []rune {
+	var missing []rune
+
+	for _, char := range text {
+		if _, ok := glyphs[unicode.ToUpper(char)]; !ok && !slices.Contains(missing, char) {
+			missing = append(missing, char)
+		}
+	}
+
+	return missing
+}
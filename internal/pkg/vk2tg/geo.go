@@ -0,0 +1,224 @@
+package vk2tg
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// geoContextRunes is how far before a bare pair of numbers a geo keyword is looked for.
+const geoContextRunes = 40
+
+var (
+	// coordinatesPattern finds "55.7558, 37.6173"-like pairs of decimal degrees.
+	coordinatesPattern = regexp.MustCompile(`(-?\d{1,2}\.\d{3,})\s*[,;\s]\s*(-?\d{1,3}\.\d{3,})`)
+	// degreesPattern finds pairs written with degree signs, "55.7558° N, 37.6173° E".
+	degreesPattern = regexp.MustCompile(
+		`(-?\d{1,2}\.\d+)°\s*([NSСЮnsсю])?\s*[,;\s]\s*(-?\d{1,3}\.\d+)°\s*([EWВЗewвз])?`)
+	// geoKeywordPattern marks text right before a bare pair as a location.
+	geoKeywordPattern = regexp.MustCompile(
+		`(?i)(координат|геолокац|геометк|локаци|на карте|gps|coordinates|location|geo:|maps|[?&](?:q|query)=)`)
+)
+
+// Geo controls how a route handles posts with coordinates.
+// Locations come from the place attached to the VK post or from coordinates in its text,
+// street addresses in the text are not geocoded.
+type Geo struct {
+	Send bool  `yaml:"send"`
+	Near *Near `yaml:"near"`
+}
+
+// Near limits a route to posts within a radius of a point.
+type Near struct {
+	Latitude    float64 `yaml:"latitude"`
+	Longitude   float64 `yaml:"longitude"`
+	RadiusKm    float64 `yaml:"radiusKm"`
+	KeepUnknown bool    `yaml:"keepUnknown"`
+}
+
+// geoPoint is a location found in a post.
+type geoPoint struct {
+	Latitude  float64
+	Longitude float64
+	Title     string
+	Address   string
+}
+
+func (geo *Geo) init() error {
+	if geo.Near == nil {
+		return nil
+	}
+
+	if geo.Near.RadiusKm <= 0 {
+		return errors.New("radius must be positive")
+	}
+
+	//nolint:mnd // coordinate bounds
+	if math.Abs(geo.Near.Latitude) > 90 || math.Abs(geo.Near.Longitude) > 180 {
+		return errors.New("point is out of range")
+	}
+
+	return nil
+}
+
+// match reports whether the post passes the distance filter.
+func (geo *Geo) match(post *vkObject.WallWallpost) bool {
+	if geo.Near == nil {
+		return true
+	}
+
+	point := postGeo(post)
+	if point == nil {
+		return geo.Near.KeepUnknown
+	}
+
+	return distanceKm(point.Latitude, point.Longitude, geo.Near.Latitude, geo.Near.Longitude) <= geo.Near.RadiusKm
+}
+
+// postGeo returns the location attached to the post or, failing that, coordinates written in its text.
+func postGeo(post *vkObject.WallWallpost) *geoPoint {
+	place := post.Geo.Place
+
+	if place.Latitude != 0 || place.Longitude != 0 {
+		return &geoPoint{
+			Latitude:  place.Latitude,
+			Longitude: place.Longitude,
+			Title:     place.Title,
+			Address:   place.Address,
+		}
+	}
+
+	if point := parseCoordinates(post.Geo.Coordinates, false); point != nil {
+		point.Title = place.Title
+		point.Address = place.Address
+
+		return point
+	}
+
+	return parseCoordinates(post.Text, true)
+}
+
+// parseCoordinates finds the first valid latitude/longitude pair in text.
+// In free text a pair needs degree signs or a geo keyword or map link right before it,
+// so that prices, versions and other numbers aren't taken for a location.
+func parseCoordinates(text string, needContext bool) *geoPoint {
+	if point := parseDegrees(text); point != nil {
+		return point
+	}
+
+	for _, match := range coordinatesPattern.FindAllStringSubmatchIndex(text, -1) {
+		if needContext && !geoContext(text[:match[0]]) {
+			continue
+		}
+
+		if point := newGeoPoint(text[match[2]:match[3]], text[match[4]:match[5]]); point != nil {
+			return point
+		}
+	}
+
+	return nil
+}
+
+// parseDegrees finds the first pair written with degree signs, hemisphere letters flip the sign.
+func parseDegrees(text string) *geoPoint {
+	for _, match := range degreesPattern.FindAllStringSubmatch(text, -1) {
+		point := newGeoPoint(match[1], match[3])
+		if point == nil {
+			continue
+		}
+
+		if strings.ContainsAny(match[2], "SsЮю") {
+			point.Latitude = -math.Abs(point.Latitude)
+		}
+
+		if strings.ContainsAny(match[4], "WwЗз") {
+			point.Longitude = -math.Abs(point.Longitude)
+		}
+
+		return point
+	}
+
+	return nil
+}
+
+// geoContext reports whether the text right before a pair of numbers says it is a location.
+func geoContext(before string) bool {
+	runes := []rune(before)
+	if len(runes) > geoContextRunes {
+		runes = runes[len(runes)-geoContextRunes:]
+	}
+
+	return geoKeywordPattern.MatchString(string(runes))
+}
+
+func newGeoPoint(latitudeText, longitudeText string) *geoPoint {
+	latitude, err := strconv.ParseFloat(latitudeText, 64)
+	if err != nil {
+		return nil
+	}
+
+	longitude, err := strconv.ParseFloat(longitudeText, 64)
+	if err != nil {
+		return nil
+	}
+
+	//nolint:mnd // coordinate bounds
+	if math.Abs(latitude) > 90 || math.Abs(longitude) > 180 || latitude == 0 && longitude == 0 {
+		return nil
+	}
+
+	return &geoPoint{Latitude: latitude, Longitude: longitude}
+}
+
+// distanceKm is the great-circle distance between two points by the haversine formula.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 } //nolint:mnd // degrees
+
+	deltaLat := toRadians(lat2 - lat1)
+	deltaLon := toRadians(lon2 - lon1)
+
+	haversine := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(haversine))
+}
+
+// sendable returns a Telegram venue when the place is named and a plain location otherwise.
+func (point *geoPoint) sendable() any {
+	location := tb.Location{Lat: float32(point.Latitude), Lng: float32(point.Longitude)}
+
+	if point.Title == "" {
+		return &location
+	}
+
+	address := point.Address
+	if strings.TrimSpace(address) == "" {
+		address = point.Title
+	}
+
+	return &tb.Venue{Location: location, Title: point.Title, Address: address}
+}
+
+// sendGeo sends the post location as a reply to the forwarded message.
+func (vtCli *VTClinent) sendGeo(post *vkObject.WallWallpost, msg *tb.Message, silent bool) {
+	point := postGeo(post)
+	if point == nil {
+		return
+	}
+
+	_, err := vtCli.tgClient.Send(msg.Chat, point.sendable(), &tb.SendOptions{
+		ReplyTo:             msg,
+		DisableNotification: silent,
+	})
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't send location: %s", post.ID, err)
+	}
+}
@@ -0,0 +1,89 @@
+package vk2tg
+
+import (
+	"math"
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+)
+
+// TestPostGeo tests finding coordinates in the geo field and the post text.
+func TestPostGeo(t *testing.T) {
+	tests := []struct {
+		name     string
+		post     *vkObject.WallWallpost
+		expected *geoPoint
+	}{
+		{
+			name: "place with title",
+			post: &vkObject.WallWallpost{Geo: vkObject.BaseGeo{Place: vkObject.BasePlace{
+				Latitude: 55.75, Longitude: 37.61, Title: "Парк Горького",
+			}}},
+			expected: &geoPoint{Latitude: 55.75, Longitude: 37.61, Title: "Парк Горького"},
+		},
+		{
+			name:     "coordinates field",
+			post:     &vkObject.WallWallpost{Geo: vkObject.BaseGeo{Coordinates: "59.9386 30.3141"}},
+			expected: &geoPoint{Latitude: 59.9386, Longitude: 30.3141},
+		},
+		{
+			name:     "coordinates in text",
+			post:     &vkObject.WallWallpost{Text: "Видели у подъезда, точка на карте: 55.6581, 37.5022. Звоните!"},
+			expected: &geoPoint{Latitude: 55.6581, Longitude: 37.5022},
+		},
+		{
+			name:     "degrees with hemispheres",
+			post:     &vkObject.WallWallpost{Text: "Нашли на 33.8688° S, 151.2093° E"},
+			expected: &geoPoint{Latitude: -33.8688, Longitude: 151.2093},
+		},
+		{
+			name:     "map link",
+			post:     &vkObject.WallWallpost{Text: "https://www.google.com/maps/@55.7512,37.6184,15z"},
+			expected: &geoPoint{Latitude: 55.7512, Longitude: 37.6184},
+		},
+		{
+			name: "numbers without geo context",
+			post: &vkObject.WallWallpost{Text: "Корм 55.750, лоток 37.610 — отдам всё"},
+		},
+		{
+			name: "keyword too far before the numbers",
+			post: &vkObject.WallWallpost{Text: "Координаты не знаем, но по дороге видели много кошек и собак, цены 55.750, 37.610"},
+		},
+		{
+			name: "phone number is not coordinates",
+			post: &vkObject.WallWallpost{Text: "Звоните 8-900-123-45-67, награда 10.000 руб"},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := postGeo(testCase.post)
+
+			if testCase.expected == nil {
+				if result != nil {
+					t.Fatalf("expected no location, got %+v", result)
+				}
+
+				return
+			}
+
+			if result == nil || *result != *testCase.expected {
+				t.Errorf("expected %+v, got %+v", testCase.expected, result)
+			}
+		})
+	}
+}
+
+// TestDistanceKm tests the haversine distance.
+func TestDistanceKm(t *testing.T) {
+	// Moscow to Saint Petersburg is about 634 km.
+	distance := distanceKm(55.7558, 37.6173, 59.9386, 30.3141)
+
+	if math.Abs(distance-634) > 5 {
+		t.Errorf("expected about 634 km, got %.1f", distance)
+	}
+
+	if distanceKm(55.7558, 37.6173, 55.7558, 37.6173) != 0 {
+		t.Errorf("expected zero distance for the same point")
+	}
+}
@@ -0,0 +1,134 @@
+package vk2tg
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// timeouts for HTTP server.
+const (
+	readHeaderTimeout = 3 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 10 * time.Second
+)
+
+// webhookSecretHeader is the header Telegram uses to pass the webhook secret token.
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// WithHTTP enables the HTTP server with health checks on the given address.
+func (vtCli *VTClinent) WithHTTP(listen string) *VTClinent {
+	vtCli.config.HTTPListen = listen
+
+	return vtCli
+}
+
+// WithWebhook switches the bot from long polling to a Telegram webhook.
+// Updates are received on the path of publicURL served by the HTTP server.
+func (vtCli *VTClinent) WithWebhook(publicURL, secret string) *VTClinent {
+	vtCli.config.WebhookURL = publicURL
+	vtCli.config.WebhookSecret = secret
+
+	return vtCli
+}
+
+// poller returns the update source for the bot: a webhook if configured, long polling otherwise.
+func (vtCli *VTClinent) poller() (tb.Poller, error) {
+	if vtCli.config.WebhookURL == "" {
+		return &tb.LongPoller{Timeout: 10 * time.Second}, nil
+	}
+
+	if vtCli.config.HTTPListen == "" {
+		return nil, errors.New("webhook mode requires the HTTP server to be enabled")
+	}
+
+	publicURL, err := url.Parse(vtCli.config.WebhookURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid webhook URL")
+	}
+
+	if publicURL.Scheme != "https" {
+		return nil, errors.Newf("webhook URL must use https, got %q", publicURL.Scheme)
+	}
+
+	vtCli.webhook = &tb.Webhook{
+		SecretToken: vtCli.config.WebhookSecret,
+		Endpoint:    &tb.WebhookEndpoint{PublicURL: vtCli.config.WebhookURL},
+	}
+
+	return vtCli.webhook, nil
+}
+
+// startHTTP starts the shared HTTP server with health checks and, in webhook mode, the webhook handler.
+func (vtCli *VTClinent) startHTTP() error {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", vtCli.healthz)
+	mux.HandleFunc("GET /metrics", vtCli.metrics)
+	mux.HandleFunc("GET /stats", vtCli.statsJSON)
+
+	if vtCli.webhook != nil {
+		publicURL, err := url.Parse(vtCli.config.WebhookURL)
+		if err != nil {
+			return errors.Wrap(err, "invalid webhook URL")
+		}
+
+		path := publicURL.Path
+		if path == "" {
+			path = "/"
+		}
+
+		mux.HandleFunc("POST "+path, vtCli.webhookHandler)
+	}
+
+	vtCli.httpServer = &http.Server{
+		Addr:              vtCli.config.HTTPListen,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+
+	go func() {
+		vtCli.logger.Printf("HTTP server listening on %s", vtCli.config.HTTPListen)
+
+		err := vtCli.httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			vtCli.logger.Printf("HTTP server failed: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+func (vtCli *VTClinent) healthz(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	_, err := writer.Write([]byte("ok\n"))
+	if err != nil {
+		vtCli.logger.Printf("Can't write health response: %s", err)
+	}
+}
+
+// webhookHandler rejects updates without a valid secret token before handing them to telebot.
+// Followers refuse updates, so state changes and publishing stay with the leader.
+func (vtCli *VTClinent) webhookHandler(writer http.ResponseWriter, request *http.Request) {
+	if vtCli.config.WebhookSecret != "" &&
+		request.Header.Get(webhookSecretHeader) != vtCli.config.WebhookSecret {
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	// Only the leader handles updates, Telegram delivers them again until it gets them.
+	if !vtCli.fenced() {
+		http.Error(writer, "Not the leader", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	vtCli.webhook.ServeHTTP(writer, request)
+}
@@ -0,0 +1,413 @@
+package vk2tg
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// Languages of the bot UI.
+const (
+	langEN = "en"
+	langRU = "ru"
+)
+
+// defaultLanguage is used for chats without a setting, it is the language of the original audience.
+const defaultLanguage = langRU
+
+// languages lists the supported languages in the order SetCommands registers them.
+var languages = []string{langEN, langRU}
+
+// catalog holds every user-facing string by language and key, English is the reference.
+// Strings with arguments are fmt formats.
+var catalog = map[string]map[string]string{
+	langEN: {
+		"cmd.mute":     "(Un)mute bot",
+		"cmd.pause":    "(Un)pause bot",
+		"cmd.status":   "Show current status",
+		"cmd.history":  "List recently forwarded posts",
+		"cmd.resend":   "Send an archived post again",
+		"cmd.search":   "Search forwarded posts",
+		"cmd.stats":    "Show daily statistics",
+		"cmd.comment":  "Reply to a post to comment on VK",
+		"cmd.language": "Choose the bot language",
+
+		"status.header":      "I'm fine",
+		"status.lastPost":    "Last post date:\t%s",
+		"status.received":    "Received in:\t%s",
+		"status.uptime":      "Uptime:\t%s",
+		"status.paused":      "Paused:\t%s",
+		"status.sound":       "Sound:\t%s",
+		"status.yes":         "yes",
+		"status.no":          "no",
+		"status.quietHours":  "Quiet hours (%s):\t%s",
+		"status.quietActive": ", active until %s",
+		"status.queue":       "Queue (%s):\t%s",
+		"status.sourcesOK":   "Sources:\tok",
+		"status.source":      "Source %s:\t%s, %d failures since %s, retry at %s",
+		"status.noLeader":    "Replica:\t%s, follower, no leader",
+		"status.follower":    "Replica:\t%s, follower of %s",
+		"status.leader":      "Replica:\t%s, leader since %s, token %d",
+		"leader.moved":       "Another replica is taking over, try again in a few seconds",
+		"queue.posts":        "%d posts",
+		"queue.lastSent":     ", last sent %s",
+		"queue.unknown":      "unknown: %s",
+
+		"pause.on":  "Paused! Send /pause to continue",
+		"pause.off": "Unpaused! Send /pause to stop",
+		"mute.on":   "Muted! Send /mute to go loud",
+		"mute.off":  "Unmuted! Send /mute to go silent",
+
+		"archive.error":      "Can't read archive",
+		"history.usage":      "Usage: /history [n]",
+		"history.title":      "History: %d",
+		"search.usage":       "Usage: /search <words>",
+		"search.title":       "Found: %d",
+		"search.nothing":     "Nothing found",
+		"resend.usage":       "Usage: /resend <post> [chat ID]",
+		"resend.badRef":      "%q is not a post reference",
+		"resend.badChat":     "Invalid chat ID",
+		"resend.failed":      "Can't resend: %s",
+		"resend.notFound":    "Post not found in archive",
+		"stats.usage":        "Usage: /stats [days]",
+		"stats.error":        "Can't read stats",
+		"stats.sources":      "Source",
+		"stats.routes":       "Route",
+		"stats.filters":      "Filter",
+		"stats.posts":        "posts",
+		"stats.matched":      "match",
+		"stats.duplicates":   "dup",
+		"stats.delivered":    "sent",
+		"stats.failed":       "fail",
+		"comment.usage":      "Reply to a forwarded post with /comment <text>",
+		"comment.notPost":    "Not a forwarded post",
+		"comment.feed":       "Feed items can't be commented on",
+		"comment.refused":    "VK refused the comment: %s",
+		"comment.done":       "💬 Commented: %s",
+		"language.current":   "Language: %s\nSend /language en, ru or auto to change it",
+		"language.set":       "Language set to English",
+		"language.auto":      "The language of your Telegram app is used now",
+		"language.forbidden": "Only admins may change the language of a group",
+
+		"moderation.approve":    "✅ Approve",
+		"moderation.reject":     "❌ Reject",
+		"moderation.edit":       "✏️ Edit caption",
+		"moderation.preview":    "🛂 Moderation: %s\nSource: %s\n%s\n\n%s",
+		"moderation.adminsOnly": "Admins only",
+		"moderation.expired":    "Expired or already handled",
+		"moderation.loadError":  "Can't load post",
+		"moderation.noRoute":    "Route no longer exists",
+		"moderation.approved":   "Approved",
+		"moderation.approvedBy": "✅ Approved by %s",
+		"moderation.rejected":   "Rejected",
+		"moderation.rejectedBy": "❌ Rejected by %s",
+		"moderation.prompt":     "Reply to this message with the new caption",
+		"moderation.saveError":  "Can't save caption",
+		"moderation.updated":    "Caption updated",
+
+		"actions.handled":        "✅ Handled",
+		"actions.less":           "🔕 Less like this",
+		"actions.tooOld":         "Message is too old",
+		"actions.handledBy":      "✅ Handled by %s at %s",
+		"actions.alreadyHandled": "Already handled by %s",
+		"actions.saveError":      "Can't save",
+		"actions.marked":         "Marked as handled",
+		"actions.noTags":         "No hashtags to mute",
+		"actions.loadMutesError": "Can't load your mutes",
+		"actions.saveMutesError": "Can't save your mutes",
+		"actions.muted":          "🔕 Muted %s",
+		"actions.unmuted":        "🔔 Unmuted %s",
+
+		"post.open":   "🌎 Open post",
+		"post.write":  "✍️ Message author",
+		"digest.head": "📰 Digest: %d",
+		"dedup.also":  "🔁 Also published in: %s",
+
+		"alert.failing":   "⚠️ Source %s: %s\n%d failures since %s, next retry in %s\n\n%s",
+		"alert.recovered": "✅ Source %s works again after %d failures since %s",
+
+		"vkerror.auth":    "authorization failed, the token is probably expired or revoked",
+		"vkerror.tooMany": "rate limited",
+		"vkerror.access":  "access denied",
+		"vkerror.captcha": "captcha needed",
+		"vkerror.other":   "request failed",
+	},
+	langRU: {
+		"cmd.mute":     "Включить или выключить звук",
+		"cmd.pause":    "Приостановить или продолжить",
+		"cmd.status":   "Показать состояние",
+		"cmd.history":  "Последние пересланные посты",
+		"cmd.resend":   "Отправить пост из архива ещё раз",
+		"cmd.search":   "Искать пересланные посты",
+		"cmd.stats":    "Статистика по дням",
+		"cmd.comment":  "Ответьте на пост, чтобы прокомментировать во ВКонтакте",
+		"cmd.language": "Выбрать язык бота",
+
+		"status.header":      "Всё в порядке",
+		"status.lastPost":    "Последний пост:\t%s",
+		"status.received":    "Получен:\t%s",
+		"status.uptime":      "Работает:\t%s",
+		"status.paused":      "Пауза:\t%s",
+		"status.sound":       "Звук:\t%s",
+		"status.yes":         "да",
+		"status.no":          "нет",
+		"status.quietHours":  "Тихие часы (%s):\t%s",
+		"status.quietActive": ", до %s",
+		"status.queue":       "Очередь (%s):\t%s",
+		"status.sourcesOK":   "Источники:\tв порядке",
+		"status.source":      "Источник %s:\t%s, ошибок: %d с %s, повтор в %s",
+		"status.noLeader":    "Реплика:\t%s, ведомая, ведущей нет",
+		"status.follower":    "Реплика:\t%s, ведомая у %s",
+		"status.leader":      "Реплика:\t%s, ведущая с %s, токен %d",
+		"leader.moved":       "Бот переходит на другую реплику, попробуйте через несколько секунд",
+		"queue.posts":        "постов: %d",
+		"queue.lastSent":     ", последний отправлен %s",
+		"queue.unknown":      "неизвестно: %s",
+
+		"pause.on":  "Пауза! Отправьте /pause, чтобы продолжить",
+		"pause.off": "Продолжаем! Отправьте /pause, чтобы остановить",
+		"mute.on":   "Звук выключен! Отправьте /mute, чтобы включить",
+		"mute.off":  "Звук включён! Отправьте /mute, чтобы выключить",
+
+		"archive.error":      "Не удалось прочитать архив",
+		"history.usage":      "Использование: /history [n]",
+		"history.title":      "История: %d",
+		"search.usage":       "Использование: /search <слова>",
+		"search.title":       "Найдено: %d",
+		"search.nothing":     "Ничего не найдено",
+		"resend.usage":       "Использование: /resend <пост> [ID чата]",
+		"resend.badRef":      "%q — не ссылка на пост",
+		"resend.badChat":     "Неверный ID чата",
+		"resend.failed":      "Не удалось отправить: %s",
+		"resend.notFound":    "Пост не найден в архиве",
+		"stats.usage":        "Использование: /stats [дни]",
+		"stats.error":        "Не удалось прочитать статистику",
+		"stats.sources":      "Источник",
+		"stats.routes":       "Маршрут",
+		"stats.filters":      "Фильтр",
+		"stats.posts":        "посты",
+		"stats.matched":      "совп",
+		"stats.duplicates":   "дубли",
+		"stats.delivered":    "отпр",
+		"stats.failed":       "сбой",
+		"comment.usage":      "Ответьте на пересланный пост командой /comment <текст>",
+		"comment.notPost":    "Это не пересланный пост",
+		"comment.feed":       "Записи лент нельзя комментировать",
+		"comment.refused":    "ВКонтакте не принял комментарий: %s",
+		"comment.done":       "💬 Прокомментировано: %s",
+		"language.current":   "Язык: %s\nОтправьте /language en, ru или auto, чтобы сменить его",
+		"language.set":       "Выбран русский язык",
+		"language.auto":      "Теперь используется язык вашего приложения Telegram",
+		"language.forbidden": "Язык группы могут менять только администраторы",
+
+		"moderation.approve":    "✅ Одобрить",
+		"moderation.reject":     "❌ Отклонить",
+		"moderation.edit":       "✏️ Изменить текст",
+		"moderation.preview":    "🛂 Модерация: %s\nИсточник: %s\n%s\n\n%s",
+		"moderation.adminsOnly": "Только для администраторов",
+		"moderation.expired":    "Истёк срок или уже решено",
+		"moderation.loadError":  "Не удалось загрузить пост",
+		"moderation.noRoute":    "Маршрута больше нет",
+		"moderation.approved":   "Одобрено",
+		"moderation.approvedBy": "✅ Одобрил(а) %s",
+		"moderation.rejected":   "Отклонено",
+		"moderation.rejectedBy": "❌ Отклонил(а) %s",
+		"moderation.prompt":     "Ответьте на это сообщение новым текстом",
+		"moderation.saveError":  "Не удалось сохранить текст",
+		"moderation.updated":    "Текст обновлён",
+
+		"actions.handled":        "✅ Обработано",
+		"actions.less":           "🔕 Меньше такого",
+		"actions.tooOld":         "Сообщение слишком старое",
+		"actions.handledBy":      "✅ Обработал(а) %s в %s",
+		"actions.alreadyHandled": "Уже обработал(а) %s",
+		"actions.saveError":      "Не удалось сохранить",
+		"actions.marked":         "Отмечено как обработанное",
+		"actions.noTags":         "Нет хэштегов, которые можно скрыть",
+		"actions.loadMutesError": "Не удалось загрузить ваши скрытые хэштеги",
+		"actions.saveMutesError": "Не удалось сохранить ваши скрытые хэштеги",
+		"actions.muted":          "🔕 Скрыто: %s",
+		"actions.unmuted":        "🔔 Снова показываем: %s",
+
+		"post.open":   "🌎 К посту",
+		"post.write":  "✍️ Написать",
+		"digest.head": "📰 Дайджест: %d",
+		"dedup.also":  "🔁 Также опубликовано: %s",
+
+		"alert.failing":   "⚠️ Источник %s: %s\nошибок: %d с %s, следующая попытка через %s\n\n%s",
+		"alert.recovered": "✅ Источник %s снова работает после %d ошибок с %s",
+
+		"vkerror.auth":    "ошибка авторизации, вероятно, токен истёк или отозван",
+		"vkerror.tooMany": "слишком много запросов",
+		"vkerror.access":  "доступ запрещён",
+		"vkerror.captcha": "нужна капча",
+		"vkerror.other":   "запрос не удался",
+	},
+}
+
+// translate returns the catalog string in the language, falling back to English and then to the key.
+func translate(lang, key string, args ...any) string {
+	text, ok := catalog[lang][key]
+	if !ok {
+		text, ok = catalog[langEN][key]
+	}
+
+	if !ok {
+		return key
+	}
+
+	if len(args) == 0 {
+		return text
+	}
+
+	return fmt.Sprintf(text, args...)
+}
+
+// localized is a catalog string with its arguments, translated once the recipient is known.
+type localized struct {
+	key  string
+	args []any
+}
+
+// in renders the text in the language, localized arguments are rendered in it as well.
+func (text localized) in(lang string) string {
+	args := make([]any, len(text.args))
+
+	for index, arg := range text.args {
+		if nested, ok := arg.(localized); ok {
+			arg = nested.in(lang)
+		}
+
+		args[index] = arg
+	}
+
+	return translate(lang, text.key, args...)
+}
+
+// supportedLanguage maps a Telegram language code such as "en-US" to a catalog language, empty if unsupported.
+func supportedLanguage(code string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if _, ok := catalog[lang]; !ok {
+		return ""
+	}
+
+	return lang
+}
+
+func languageKey(chatID int64) string {
+	return "language:" + strconv.FormatInt(chatID, 10)
+}
+
+// chatSetting returns the language set for the chat with /language, empty if there is none.
+func (vtCli *VTClinent) chatSetting(chatID int64) string {
+	data, err := vtCli.storage.Get(languageKey(chatID))
+	if err != nil {
+		return ""
+	}
+
+	return supportedLanguage(string(data))
+}
+
+// chatLanguage returns the language set for the chat or the configured default.
+func (vtCli *VTClinent) chatLanguage(chatID int64) string {
+	return cmp.Or(vtCli.chatSetting(chatID), vtCli.language)
+}
+
+// userLanguage picks the language of a reply: the chat setting,
+// the language of the user's Telegram app or the configured default.
+func (vtCli *VTClinent) userLanguage(tbContext tb.Context) string {
+	var chatLang, userLang string
+
+	if chat := tbContext.Chat(); chat != nil {
+		chatLang = vtCli.chatSetting(chat.ID)
+	}
+
+	if user := tbContext.Sender(); user != nil {
+		userLang = supportedLanguage(user.LanguageCode)
+	}
+
+	return cmp.Or(chatLang, userLang, vtCli.language)
+}
+
+// tr translates a reply for the user of the context.
+func (vtCli *VTClinent) tr(tbContext tb.Context, key string, args ...any) string {
+	return translate(vtCli.userLanguage(tbContext), key, args...)
+}
+
+func yesNo(lang string, value bool) string {
+	if value {
+		return translate(lang, "status.yes")
+	}
+
+	return translate(lang, "status.no")
+}
+
+// setLanguage handles "/language [en|ru|auto]": it shows or sets the language of the chat.
+func (vtCli *VTClinent) setLanguage(tbContext tb.Context) error {
+	arg := strings.ToLower(strings.TrimSpace(tbContext.Message().Payload))
+	chat := tbContext.Chat()
+
+	if arg == "" {
+		return errors.Wrap(
+			tbContext.Send(vtCli.tr(tbContext, "language.current", vtCli.userLanguage(tbContext))),
+			"error on sending message",
+		)
+	}
+
+	if chat.Type != tb.ChatPrivate && !vtCli.isAdmin(tbContext.Sender()) {
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "language.forbidden")), "error on sending message")
+	}
+
+	if arg == "auto" {
+		err := vtCli.storage.Delete(languageKey(chat.ID))
+		if err != nil {
+			return errors.Wrap(err, "can't reset language")
+		}
+
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "language.auto")), "error on sending message")
+	}
+
+	if !slices.Contains(languages, arg) {
+		return errors.Wrap(
+			tbContext.Send(vtCli.tr(tbContext, "language.current", vtCli.userLanguage(tbContext))),
+			"error on sending message",
+		)
+	}
+
+	err := vtCli.storage.Set(languageKey(chat.ID), []byte(arg), 0)
+	if err != nil {
+		return errors.Wrap(err, "can't save language")
+	}
+
+	vtCli.logger.Printf("Language of chat %d set to %s", chat.ID, arg)
+
+	return errors.Wrap(tbContext.Send(translate(arg, "language.set")), "error on sending message")
+}
+
+// setCommands registers the command list for every language, the default language also serves the rest.
+func (vtCli *VTClinent) setCommands() error {
+	names := []string{"mute", "pause", "status", "history", "resend", "search", "stats", "language"}
+	if vtCli.vkReplies != nil {
+		names = append(names, "comment")
+	}
+
+	for _, lang := range append([]string{""}, languages...) {
+		commands := make([]tb.Command, 0, len(names))
+
+		for _, name := range names {
+			commands = append(commands, tb.Command{Text: name, Description: translate(cmp.Or(lang, vtCli.language), "cmd."+name)})
+		}
+
+		err := vtCli.tgClient.SetCommands(commands, lang)
+		if err != nil {
+			return errors.Wrapf(err, "can't set commands for %q", lang)
+		}
+	}
+
+	return nil
+}
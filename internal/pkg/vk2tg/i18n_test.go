@@ -0,0 +1,71 @@
+package vk2tg
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+)
+
+var verbPattern = regexp.MustCompile(`%[a-z]`)
+
+// TestCatalog tests that every language has every English string with the same format verbs.
+func TestCatalog(t *testing.T) {
+	for _, lang := range languages {
+		for key, reference := range catalog[langEN] {
+			text, ok := catalog[lang][key]
+			if !ok {
+				t.Errorf("%s: missing %s", lang, key)
+
+				continue
+			}
+
+			if !slices.Equal(verbPattern.FindAllString(text, -1), verbPattern.FindAllString(reference, -1)) {
+				t.Errorf("%s: %s has other format verbs than in English", lang, key)
+			}
+		}
+
+		if len(catalog[lang]) != len(catalog[langEN]) {
+			t.Errorf("%s: has %d strings, English has %d", lang, len(catalog[lang]), len(catalog[langEN]))
+		}
+	}
+}
+
+// TestChatLanguage tests that the chat setting wins over the default, which renders the default template.
+func TestChatLanguage(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+
+	err := vtCli.storage.Set(languageKey(-100), []byte(langEN), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		chatID int64
+		button string
+	}{
+		{"setting", -100, "🌎 Open post"},
+		{"default", -200, "🌎 К посту"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			route := &Route{Name: "test", ChatID: testCase.chatID}
+
+			_, options, err := vtCli.renderPost(route, route.ChatID, &vkObject.WallWallpost{ID: 1, OwnerID: -5}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if button := options.ReplyMarkup.InlineKeyboard[0][0].Text; button != testCase.button {
+				t.Errorf("expected %q, got %q", testCase.button, button)
+			}
+		})
+	}
+
+	if lang := supportedLanguage("en-US"); lang != langEN {
+		t.Errorf("expected en-US to map to %s, got %q", langEN, lang)
+	}
+}
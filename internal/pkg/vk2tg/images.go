@@ -0,0 +1,314 @@
+package vk2tg
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// defaultJPEGQuality is used to re-encode transformed photos.
+	defaultJPEGQuality = 85
+	// defaultWatermarkOpacity keeps the photo visible through the watermark.
+	defaultWatermarkOpacity = 0.5
+	// watermarkMarginRatio is the gap between the watermark and the photo edges, in parts of the short side.
+	watermarkMarginRatio = 50
+	// watermarkTextRatio sets the text size to one part of the short side of the photo.
+	watermarkTextRatio = 25
+	// watermarkImageRatio limits a PNG watermark to one part of the photo width.
+	watermarkImageRatio = 4
+)
+
+// Watermark positions.
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+)
+
+// Images transforms photos of a route before upload: watermark, downscale and JPEG re-encoding.
+// Photos are downloaded for that even when media downloads are not configured.
+type Images struct {
+	Watermark    *Watermark `yaml:"watermark"`
+	MaxDimension int        `yaml:"maxDimension"`
+	Quality      int        `yaml:"quality"`
+
+	// id tells apart file IDs of photos transformed with different settings.
+	id string
+}
+
+// Watermark is a text or PNG overlay. The built-in font only covers Latin and Cyrillic capitals,
+// digits and common punctuation, use a PNG for anything else.
+type Watermark struct {
+	Text     string  `yaml:"text"`
+	Image    string  `yaml:"image"`
+	Position string  `yaml:"position"`
+	Opacity  float64 `yaml:"opacity"`
+
+	overlay *image.RGBA
+}
+
+func (images *Images) init() error {
+	if images.Quality == 0 {
+		images.Quality = defaultJPEGQuality
+	}
+
+	if images.Quality < 1 || images.Quality > 100 {
+		return errors.New("quality must be between 1 and 100")
+	}
+
+	if images.MaxDimension < 0 {
+		return errors.New("max dimension must be positive")
+	}
+
+	hasher := fnv.New64a()
+	fmt.Fprintf(hasher, "%d/%d", images.MaxDimension, images.Quality)
+
+	if images.Watermark != nil {
+		err := images.Watermark.init()
+		if err != nil {
+			return errors.Wrap(err, "invalid watermark")
+		}
+
+		watermark := images.Watermark
+		fmt.Fprintf(hasher, "/%q/%s/%s/%g", watermark.Text, watermark.Image, watermark.Position, watermark.Opacity)
+
+		if watermark.overlay != nil {
+			_, _ = hasher.Write(watermark.overlay.Pix)
+		}
+	}
+
+	images.id = strconv.FormatUint(hasher.Sum64(), 16)
+
+	return nil
+}
+
+func (watermark *Watermark) init() error {
+	if (watermark.Text == "") == (watermark.Image == "") {
+		return errors.New("either text or image is required")
+	}
+
+	if missing := missingGlyphs(watermark.Text); len(missing) > 0 {
+		return errors.Newf("the built-in font has no %q, text may only use Latin and Cyrillic letters, digits and punctuation, "+
+			"use an image for other scripts", string(missing))
+	}
+
+	if watermark.Opacity == 0 {
+		watermark.Opacity = defaultWatermarkOpacity
+	}
+
+	if watermark.Opacity < 0 || watermark.Opacity > 1 {
+		return errors.New("opacity must be between 0 and 1")
+	}
+
+	switch watermark.Position {
+	case "":
+		watermark.Position = PositionBottomRight
+	case PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+	default:
+		return errors.Newf("unknown position %q", watermark.Position)
+	}
+
+	if watermark.Image == "" {
+		return nil
+	}
+
+	file, err := os.Open(watermark.Image)
+	if err != nil {
+		return errors.Wrap(err, "can't open image")
+	}
+	defer file.Close()
+
+	overlay, err := png.Decode(file)
+	if err != nil {
+		return errors.Wrap(err, "can't decode PNG")
+	}
+
+	watermark.overlay = image.NewRGBA(image.Rect(0, 0, overlay.Bounds().Dx(), overlay.Bounds().Dy()))
+	draw.Draw(watermark.overlay, watermark.overlay.Bounds(), overlay, overlay.Bounds().Min, draw.Src)
+
+	return nil
+}
+
+// apply transforms the photo file in place.
+func (images *Images) apply(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "can't open photo")
+	}
+
+	src, _, err := image.Decode(file)
+	file.Close()
+
+	if err != nil {
+		return errors.Wrap(err, "can't decode photo")
+	}
+
+	file, err = os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "can't rewrite photo")
+	}
+	defer file.Close()
+
+	err = jpeg.Encode(file, images.transform(src), &jpeg.Options{Quality: images.Quality})
+	if err != nil {
+		return errors.Wrap(err, "can't encode photo")
+	}
+
+	return nil
+}
+
+func (images *Images) transform(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := fit(bounds.Dx(), bounds.Dy(), images.MaxDimension)
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	if width == bounds.Dx() && height == bounds.Dy() {
+		draw.Draw(canvas, canvas.Bounds(), src, bounds.Min, draw.Src)
+	} else {
+		downscale(canvas, src)
+	}
+
+	if images.Watermark != nil {
+		images.Watermark.draw(canvas)
+	}
+
+	return canvas
+}
+
+// fit scales the size down to make the longer side at most limit, keeping the aspect ratio.
+func fit(width, height, limit int) (int, int) {
+	longer := max(width, height)
+	if limit <= 0 || longer <= limit {
+		return width, height
+	}
+
+	return max(1, width*limit/longer), max(1, height*limit/longer)
+}
+
+// downscale fills dst with src averaging the source pixels under every destination pixel.
+func downscale(dst *image.RGBA, src image.Image) {
+	srcBounds := src.Bounds()
+	dstWidth, dstHeight := dst.Bounds().Dx(), dst.Bounds().Dy()
+
+	for y := range dstHeight {
+		top := srcBounds.Min.Y + y*srcBounds.Dy()/dstHeight
+		bottom := max(top+1, srcBounds.Min.Y+(y+1)*srcBounds.Dy()/dstHeight)
+
+		for x := range dstWidth {
+			left := srcBounds.Min.X + x*srcBounds.Dx()/dstWidth
+			right := max(left+1, srcBounds.Min.X+(x+1)*srcBounds.Dx()/dstWidth)
+
+			dst.Set(x, y, averageColor(src, image.Rect(left, top, right, bottom)))
+		}
+	}
+}
+
+func averageColor(src image.Image, area image.Rectangle) color.RGBA64 {
+	var red, green, blue, alpha uint64
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			pixelRed, pixelGreen, pixelBlue, pixelAlpha := src.At(x, y).RGBA()
+			red += uint64(pixelRed)
+			green += uint64(pixelGreen)
+			blue += uint64(pixelBlue)
+			alpha += uint64(pixelAlpha)
+		}
+	}
+
+	count := uint64(area.Dx() * area.Dy()) //nolint:gosec // area is never empty
+
+	return color.RGBA64{
+		R: uint16(red / count),   //nolint:gosec // average of uint16 values
+		G: uint16(green / count), //nolint:gosec // average of uint16 values
+		B: uint16(blue / count),  //nolint:gosec // average of uint16 values
+		A: uint16(alpha / count), //nolint:gosec // average of uint16 values
+	}
+}
+
+// draw blends the watermark into the canvas.
+func (watermark *Watermark) draw(canvas *image.RGBA) {
+	bounds := canvas.Bounds()
+	shorter := min(bounds.Dx(), bounds.Dy())
+
+	overlay := watermark.overlay
+	if overlay == nil {
+		overlay = renderText(watermark.Text, bounds.Dx(), max(1, shorter/(glyphHeight*watermarkTextRatio)))
+	} else if limit := bounds.Dx() / watermarkImageRatio; overlay.Bounds().Dx() > limit {
+		width, height := fit(overlay.Bounds().Dx(), overlay.Bounds().Dy(), max(1, limit))
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		downscale(scaled, overlay)
+		overlay = scaled
+	}
+
+	size := overlay.Bounds().Size()
+	margin := shorter / watermarkMarginRatio
+	origin := image.Point{X: bounds.Dx() - size.X - margin, Y: bounds.Dy() - size.Y - margin}
+
+	switch watermark.Position {
+	case PositionTopLeft:
+		origin = image.Point{X: margin, Y: margin}
+	case PositionTopRight:
+		origin.Y = margin
+	case PositionBottomLeft:
+		origin.X = margin
+	case PositionCenter:
+		origin = image.Point{X: (bounds.Dx() - size.X) / 2, Y: (bounds.Dy() - size.Y) / 2}
+	}
+
+	mask := image.NewUniform(color.Alpha{A: uint8(watermark.Opacity * 0xff)})
+	draw.DrawMask(canvas, image.Rectangle{Min: origin, Max: origin.Add(size)}, overlay, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// renderText draws white text with a dark shadow, each font pixel taking scale pixels.
+// The scale shrinks until the text fits maxWidth.
+func renderText(text string, maxWidth, scale int) *image.RGBA {
+	runes := []rune(text)
+	cellWidth := glyphWidth + 1
+
+	for scale > 1 && (len(runes)*cellWidth+2)*scale > maxWidth {
+		scale--
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, (len(runes)*cellWidth+2)*scale, (glyphHeight+3)*scale))
+
+	for _, layer := range []struct {
+		color  color.Color
+		offset int
+	}{
+		{color.Black, 1},
+		{color.White, 0},
+	} {
+		fill := image.NewUniform(layer.color)
+
+		for index, char := range runes {
+			bitmap := glyph(char)
+
+			for row := range glyphHeight {
+				for column := range glyphWidth {
+					if bitmap[row][column] != '#' {
+						continue
+					}
+
+					left := (1 + index*cellWidth + column + layer.offset) * scale
+					top := (1 + row + layer.offset) * scale
+
+					draw.Draw(canvas, image.Rect(left, top, left+scale, top+scale), fill, image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+
+	return canvas
+}
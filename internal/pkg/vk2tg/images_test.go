@@ -0,0 +1,106 @@
+package vk2tg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// TestFit tests downscaling to the max dimension.
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name           string
+		width, height  int
+		limit          int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{"no limit", 2000, 1000, 0, 2000, 1000},
+		{"already small", 800, 600, 1280, 800, 600},
+		{"landscape", 2560, 1440, 1280, 1280, 720},
+		{"portrait", 1000, 3000, 1500, 500, 1500},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			width, height := fit(testCase.width, testCase.height, testCase.limit)
+			if width != testCase.expectedWidth || height != testCase.expectedHeight {
+				t.Errorf("expected %dx%d, got %dx%d", testCase.expectedWidth, testCase.expectedHeight, width, height)
+			}
+		})
+	}
+}
+
+// TestGlyphs tests that every glyph of the watermark font has the right size.
+func TestGlyphs(t *testing.T) {
+	for char, bitmap := range glyphs {
+		for _, row := range bitmap {
+			if len(row) != glyphWidth {
+				t.Errorf("glyph %q has a row of %d pixels", char, len(row))
+			}
+		}
+	}
+}
+
+// TestTransform tests that the photo is downscaled and the watermark lands in its corner.
+func TestTransform(t *testing.T) {
+	images := &Images{
+		MaxDimension: 500,
+		Watermark:    &Watermark{Text: "@vk2tg", Position: PositionTopLeft, Opacity: 1},
+	}
+
+	err := images.init()
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 800))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}), image.Point{}, draw.Src)
+
+	result := images.transform(src)
+	if result.Bounds().Dx() != 500 || result.Bounds().Dy() != 400 {
+		t.Fatalf("expected 500x400, got %v", result.Bounds())
+	}
+
+	var top, bottom int
+
+	for y := range 50 {
+		for x := range 100 {
+			if result.RGBAAt(x, y) != (color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}) {
+				top++
+			}
+
+			if result.RGBAAt(x, y+350) != (color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}) {
+				bottom++
+			}
+		}
+	}
+
+	if top == 0 || bottom != 0 {
+		t.Errorf("expected the watermark only at the top, got %d changed pixels at the top and %d at the bottom", top, bottom)
+	}
+}
+
+// TestWatermarkText tests that text the built-in font can't draw is rejected.
+func TestWatermarkText(t *testing.T) {
+	tests := []struct {
+		text    string
+		invalid bool
+	}{
+		{text: "t.me/lost_pets"},
+		{text: "Poisk 24/7!"},
+		{text: "Поиск животных, Ёлки"},
+		{text: "Αναζήτηση", invalid: true},
+		{text: "pets €", invalid: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.text, func(t *testing.T) {
+			err := (&Watermark{Text: testCase.text}).init()
+			if (err != nil) != testCase.invalid {
+				t.Errorf("expected invalid=%v, got %v", testCase.invalid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package vk2tg
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// leaderKey holds the lease of the replica allowed to fetch and forward posts.
+	leaderKey = "leader"
+	// leaderEpochKey is the counter issuing fencing tokens, one per new lease.
+	leaderEpochKey = "leader:epoch"
+	// leaseTTL is how long a lease lives without renewal: followers take over within it once the leader dies.
+	leaseTTL = 10 * time.Second
+	// leaseRenewPeriod is how often the leader renews its lease and followers try to take it.
+	leaseRenewPeriod = 3 * time.Second
+)
+
+// election is the state of this replica in the leader election.
+type election struct {
+	mu sync.Mutex
+
+	replicaID string
+	leader    bool
+	// token is the fencing token of the lease, it grows with every new leader
+	token int64
+	lease []byte
+	since time.Time
+	// renewed is the last time the lease was confirmed in storage
+	renewed time.Time
+	// polled is closed once the long poller started on election has stopped, nil when not polling
+	polled chan struct{}
+}
+
+func newReplicaID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "vk2tg"
+	}
+
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+// Elector keeps campaigning for the leader lease. Only the leader fetches and forwards posts,
+// followers keep their connections warm and take over once the lease expires.
+func (vtCli *VTClinent) Elector() {
+	vtCli.WG.Add(1)
+	defer vtCli.WG.Done()
+
+	ticker := time.NewTicker(leaseRenewPeriod)
+	defer ticker.Stop()
+
+	for {
+		vtCli.campaign()
+
+		<-ticker.C
+	}
+}
+
+// campaign renews the lease of the leader or tries to take it over for a follower.
+func (vtCli *VTClinent) campaign() {
+	elect := &vtCli.election
+
+	elect.mu.Lock()
+	defer elect.mu.Unlock()
+
+	if elect.leader {
+		ok, err := vtCli.storage.Renew(leaderKey, elect.lease, leaseTTL)
+		if err != nil {
+			vtCli.logger.Printf("Can't renew leader lease: %s", err)
+
+			// Step down before the lease may have expired in storage.
+			if time.Since(elect.renewed) > leaseTTL-leaseRenewPeriod {
+				vtCli.stepDown("lease can't be renewed")
+			}
+
+			return
+		}
+
+		if !ok {
+			vtCli.stepDown("lease lost")
+
+			return
+		}
+
+		elect.renewed = time.Now()
+
+		return
+	}
+
+	_, err := vtCli.storage.Get(leaderKey)
+	if !errors.Is(err, errNotFound) {
+		return
+	}
+
+	token, err := vtCli.storage.IncrBy(leaderEpochKey, 1, 0)
+	if err != nil {
+		vtCli.logger.Printf("Can't issue fencing token: %s", err)
+
+		return
+	}
+
+	lease := []byte(elect.replicaID + "/" + strconv.FormatInt(token, 10))
+
+	ok, err := vtCli.storage.Acquire(leaderKey, lease, leaseTTL)
+	if err != nil {
+		vtCli.logger.Printf("Can't acquire leader lease: %s", err)
+
+		return
+	}
+
+	if ok {
+		vtCli.becomeLeader(token, lease)
+	}
+}
+
+// becomeLeader takes over fetching and forwarding, the caller holds the election lock.
+func (vtCli *VTClinent) becomeLeader(token int64, lease []byte) {
+	elect := &vtCli.election
+
+	// The previous leader may have moved on since start, and leading without knowing
+	// what it forwarded means forwarding it again.
+	err := vtCli.loadLastPosts()
+	if err != nil {
+		vtCli.logger.Printf("Replica %s: Can't load last posts, giving up leadership: %s", elect.replicaID, err)
+
+		err = vtCli.storage.Release(leaderKey, lease)
+		if err != nil {
+			vtCli.logger.Printf("Can't release leader lease: %s", err)
+		}
+
+		return
+	}
+
+	elect.leader = true
+	elect.token = token
+	elect.lease = lease
+	elect.since = time.Now()
+	elect.renewed = elect.since
+
+	vtCli.logger.Printf("Replica %s: Elected leader with token %d", elect.replicaID, token)
+
+	if vtCli.webhook == nil && vtCli.tgClient != nil && elect.polled == nil {
+		vtCli.startPolling()
+	}
+}
+
+// stepDown stops fetching and forwarding, the caller holds the election lock.
+func (vtCli *VTClinent) stepDown(reason string) {
+	elect := &vtCli.election
+
+	elect.leader = false
+	elect.lease = nil
+
+	vtCli.logger.Printf("Replica %s: No longer leader: %s", elect.replicaID, reason)
+
+	// Two long pollers of the same bot conflict, so only the leader polls.
+	// The poller is stopped before the election lock is released, so a quick
+	// re-election can't start polling while the old poller is shutting down.
+	if elect.polled != nil {
+		vtCli.tgClient.Stop()
+		<-elect.polled
+
+		elect.polled = nil
+	}
+}
+
+// leading lets the update through to the handler only while this replica holds the lease.
+// A follower, or a leader whose lease expired, asks to try again instead.
+func (vtCli *VTClinent) leading(next tb.HandlerFunc) tb.HandlerFunc {
+	return func(tbContext tb.Context) error {
+		if vtCli.fenced() {
+			return next(tbContext)
+		}
+
+		vtCli.logger.Printf("Replica %s: Not the leader, update %d skipped", vtCli.election.replicaID, tbContext.Update().ID)
+
+		if tbContext.Callback() != nil {
+			return respond(tbContext, vtCli.tr(tbContext, "leader.moved"))
+		}
+
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "leader.moved")), "error on sending message")
+	}
+}
+
+// startedPoller closes started when the bot begins to poll.
+type startedPoller struct {
+	tb.Poller
+
+	started chan struct{}
+}
+
+func (poller *startedPoller) Poll(bot *tb.Bot, updates chan tb.Update, stop chan struct{}) {
+	close(poller.started)
+	poller.Poller.Poll(bot, updates, stop)
+}
+
+// startPolling starts long polling and waits until it runs, the caller holds the election lock.
+// Stopping the bot before Start got to polling would leave it unable to start again.
+func (vtCli *VTClinent) startPolling() {
+	poller := vtCli.tgClient.Poller
+	if wrapped, ok := poller.(*startedPoller); ok {
+		poller = wrapped.Poller
+	}
+
+	started := make(chan struct{})
+	vtCli.tgClient.Poller = &startedPoller{Poller: poller, started: started}
+
+	polled := make(chan struct{})
+	vtCli.election.polled = polled
+
+	go func() {
+		defer close(polled)
+
+		vtCli.tgClient.Start()
+	}()
+
+	select {
+	case <-started:
+	case <-polled:
+	}
+}
+
+// isLeader reports whether this replica believes it holds the lease.
+func (vtCli *VTClinent) isLeader() bool {
+	vtCli.election.mu.Lock()
+	defer vtCli.election.mu.Unlock()
+
+	return vtCli.election.leader
+}
+
+// fenced checks in storage that the lease of this replica is still current.
+// It guards side effects that must not happen twice, when a paused leader
+// may not have noticed that its lease expired.
+func (vtCli *VTClinent) fenced() bool {
+	vtCli.election.mu.Lock()
+	lease := vtCli.election.lease
+	vtCli.election.mu.Unlock()
+
+	if lease == nil {
+		return false
+	}
+
+	current, err := vtCli.storage.Get(leaderKey)
+	if err != nil {
+		vtCli.logger.Printf("Can't check leader lease: %s", err)
+
+		return false
+	}
+
+	return bytes.Equal(current, lease)
+}
+
+// leaderStatus describes the election state for /status.
+func (vtCli *VTClinent) leaderStatus(lang string) string {
+	vtCli.election.mu.Lock()
+	defer vtCli.election.mu.Unlock()
+
+	elect := &vtCli.election
+
+	if !elect.leader {
+		current, err := vtCli.storage.Get(leaderKey)
+		if err != nil {
+			return translate(lang, "status.noLeader", elect.replicaID)
+		}
+
+		return translate(lang, "status.follower", elect.replicaID, current)
+	}
+
+	return translate(lang, "status.leader",
+		elect.replicaID, elect.since.In(zone).Format(time.RFC822), elect.token)
+}
+
+// metrics exposes the election state in the Prometheus text format.
+func (vtCli *VTClinent) metrics(writer http.ResponseWriter, _ *http.Request) {
+	vtCli.election.mu.Lock()
+	leader, token := 0, vtCli.election.token
+
+	if vtCli.election.leader {
+		leader = 1
+	}
+	vtCli.election.mu.Unlock()
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	_, err := fmt.Fprintf(writer,
+		"# HELP vk2tg_leader Whether this replica is the leader.\n"+
+			"# TYPE vk2tg_leader gauge\n"+
+			"vk2tg_leader %d\n"+
+			"# HELP vk2tg_leader_token Fencing token of the last lease held by this replica.\n"+
+			"# TYPE vk2tg_leader_token gauge\n"+
+			"vk2tg_leader_token %d\n",
+		leader, token,
+	)
+	if err != nil {
+		vtCli.logger.Printf("Can't write metrics: %s", err)
+	}
+}
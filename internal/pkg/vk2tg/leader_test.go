@@ -0,0 +1,182 @@
+package vk2tg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestElection tests that only one replica leads and a follower takes over an expired lease.
+func TestElection(t *testing.T) {
+	shared := newMemoryStorage()
+
+	replicas := make([]*VTClinent, 2)
+	for index := range replicas {
+		replicas[index] = NewVTClient("", "", 0, 1)
+		replicas[index].storage = shared
+		// Webhook mode keeps the election away from starting the bot.
+		replicas[index].webhook = &tb.Webhook{}
+		replicas[index].election.replicaID = string(rune('a' + index))
+	}
+
+	first, second := replicas[0], replicas[1]
+
+	first.campaign()
+	second.campaign()
+
+	if !first.isLeader() || second.isLeader() {
+		t.Fatalf("expected only the first replica to lead")
+	}
+
+	if !first.fenced() {
+		t.Error("expected the leader lease to be current")
+	}
+
+	firstToken := first.election.token
+
+	// The lease expires while the leader is stalled.
+	err := shared.Delete(leaderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second.campaign()
+
+	if !second.isLeader() {
+		t.Fatal("expected the second replica to take over")
+	}
+
+	if second.election.token <= firstToken {
+		t.Errorf("expected a fencing token above %d, got %d", firstToken, second.election.token)
+	}
+
+	if first.fenced() {
+		t.Error("expected the stale leader to be fenced off")
+	}
+
+	first.campaign()
+
+	if first.isLeader() {
+		t.Error("expected the stale leader to step down")
+	}
+}
+
+// countingPoller counts the pollers running at once.
+type countingPoller struct {
+	running atomic.Int32
+}
+
+func (poller *countingPoller) Poll(_ *tb.Bot, _ chan tb.Update, stop chan struct{}) {
+	poller.running.Add(1)
+	defer poller.running.Add(-1)
+
+	<-stop
+}
+
+// TestLeaderPolling tests that losing and regaining leadership quickly leaves exactly one poller.
+func TestLeaderPolling(t *testing.T) {
+	poller := &countingPoller{}
+
+	vtCli := NewVTClient("", "", 0, 1)
+
+	var err error
+
+	vtCli.tgClient, err = tb.NewBot(tb.Settings{Offline: true, Poller: poller})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 20 {
+		vtCli.becomeLeader(1, []byte("lease"))
+		vtCli.stepDown("test")
+
+		if running := poller.running.Load(); running != 0 {
+			t.Fatalf("expected the poller to be stopped, %d running", running)
+		}
+	}
+
+	vtCli.becomeLeader(1, []byte("lease"))
+
+	deadline := time.Now().Add(time.Second)
+	for poller.running.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if running := poller.running.Load(); running != 1 {
+		t.Errorf("expected one poller, %d running", running)
+	}
+
+	vtCli.stepDown("test")
+}
+
+// TestLeading tests that only the leader handles updates that change state.
+func TestLeading(t *testing.T) {
+	var answered atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if strings.HasSuffix(request.URL.Path, "/answerCallbackQuery") {
+			answered.Add(1)
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	shared := newMemoryStorage()
+
+	replicas := make([]*VTClinent, 2)
+	for index := range replicas {
+		bot, err := tb.NewBot(tb.Settings{Token: "token", URL: server.URL, Offline: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		replicas[index] = NewVTClient("", "", 0, 1)
+		replicas[index].storage = shared
+		replicas[index].tgClient = bot
+		replicas[index].webhook = &tb.Webhook{}
+		replicas[index].election.replicaID = string(rune('a' + index))
+		replicas[index].campaign()
+	}
+
+	leader, follower := replicas[0], replicas[1]
+
+	var handled atomic.Int32
+
+	handler := func(tb.Context) error {
+		handled.Add(1)
+
+		return nil
+	}
+
+	update := tb.Update{Callback: &tb.Callback{ID: "1", Sender: &tb.User{ID: 1}}}
+
+	for _, replica := range replicas {
+		err := replica.leading(handler)(replica.tgClient.NewContext(update))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if handled.Load() != 1 || answered.Load() != 1 {
+		t.Errorf("expected the leader to handle the callback and the follower to answer it, got %d handled, %d answered",
+			handled.Load(), answered.Load())
+	}
+
+	recorder := httptest.NewRecorder()
+	follower.webhookHandler(recorder, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{}")))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the follower to refuse webhook updates, got %d", recorder.Code)
+	}
+
+	if !leader.fenced() {
+		t.Error("expected the leader lease to be current")
+	}
+}
@@ -0,0 +1,290 @@
+package vk2tg
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// defaultMaxFileMB is the Telegram limit for uploaded photos.
+	defaultMaxFileMB = 10
+	// defaultBudgetMB is how much disk space downloads may take at once by default.
+	defaultBudgetMB = 100
+	// mediaDownloadTimeout limits a single attachment download.
+	mediaDownloadTimeout = time.Minute
+	// fileIDTTL is how long Telegram file IDs of uploaded media are reused.
+	fileIDTTL = 30 * 24 * time.Hour
+	megabyte  = 1 << 20
+)
+
+// Media makes the bot download attachments and upload them as files
+// instead of letting Telegram fetch VK CDN URLs.
+type Media struct {
+	// MaxFileMB skips larger attachments, they are still passed by URL.
+	MaxFileMB int64 `yaml:"maxFileMB"`
+	// BudgetMB limits the disk space taken by downloads in progress.
+	BudgetMB int64 `yaml:"budgetMB"`
+	// TempDir defaults to the system temp dir.
+	TempDir string `yaml:"tempDir"`
+
+	mu   sync.Mutex
+	used int64
+}
+
+// mediaFile is an attachment ready to be sent, release frees its temp file.
+type mediaFile struct {
+	key     string
+	file    tb.File
+	cached  bool
+	release func()
+}
+
+func (media *Media) init() error {
+	if media.MaxFileMB == 0 {
+		media.MaxFileMB = defaultMaxFileMB
+	}
+
+	if media.BudgetMB == 0 {
+		media.BudgetMB = defaultBudgetMB
+	}
+
+	if media.MaxFileMB < 0 || media.BudgetMB < media.MaxFileMB {
+		return errors.New("budget must be positive and fit at least one file")
+	}
+
+	if media.TempDir != "" {
+		info, err := os.Stat(media.TempDir)
+		if err != nil {
+			return errors.Wrap(err, "invalid temp dir")
+		}
+
+		if !info.IsDir() {
+			return errors.Newf("%s is not a directory", media.TempDir)
+		}
+	}
+
+	return nil
+}
+
+// WithMedia enables downloading attachments before sending them.
+func (vtCli *VTClinent) WithMedia(media *Media) *VTClinent {
+	vtCli.media = media
+
+	return vtCli
+}
+
+// reserve takes size bytes of the budget, reporting whether they were available.
+func (media *Media) reserve(size int64) bool {
+	media.mu.Lock()
+	defer media.mu.Unlock()
+
+	if media.used+size > media.BudgetMB*megabyte {
+		return false
+	}
+
+	media.used += size
+
+	return true
+}
+
+func (media *Media) free(size int64) {
+	media.mu.Lock()
+	defer media.mu.Unlock()
+
+	media.used -= size
+}
+
+// download saves the file to the temp dir and returns its path along with a function removing it.
+func (media *Media) download(url string) (string, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mediaDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cannot create request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cannot download file")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	limit := media.MaxFileMB * megabyte
+	if resp.ContentLength > limit {
+		return "", nil, errors.Newf("file is %d bytes, over the %d MB limit", resp.ContentLength, media.MaxFileMB)
+	}
+
+	// Reserve the worst case until the real size is known.
+	reserved := limit
+	if resp.ContentLength > 0 {
+		reserved = resp.ContentLength
+	}
+
+	if !media.reserve(reserved) {
+		return "", nil, errors.New("temp dir budget exhausted")
+	}
+
+	file, err := os.CreateTemp(media.TempDir, "vk2tg-*")
+	if err != nil {
+		media.free(reserved)
+
+		return "", nil, errors.Wrap(err, "cannot create temp file")
+	}
+
+	written, err := io.Copy(file, io.LimitReader(resp.Body, limit+1))
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	// A file that can't be removed keeps holding its share of the budget.
+	release := func() {
+		removeErr := os.Remove(file.Name())
+		if removeErr != nil && !os.IsNotExist(removeErr) {
+			return
+		}
+
+		media.free(reserved)
+	}
+
+	if err != nil {
+		release()
+
+		return "", nil, errors.Wrap(err, "cannot save file")
+	}
+
+	if written > limit {
+		release()
+
+		return "", nil, errors.Newf("file is over the %d MB limit", media.MaxFileMB)
+	}
+
+	return file.Name(), release, nil
+}
+
+func fileIDKey(key string) string {
+	return "file:" + key
+}
+
+// photoKey identifies the photo for the file ID cache,
+// photos rebuilt without a VK ID, like those of /resend, are told apart by their URL.
+func photoKey(photo *vkObject.PhotosPhoto) string {
+	if photo.ID == 0 {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(photoURL(photo)))
+
+		return "photourl" + strconv.FormatUint(hash.Sum64(), 16)
+	}
+
+	return "photo" + strconv.Itoa(photo.OwnerID) + "_" + strconv.Itoa(photo.ID)
+}
+
+// fallbackMedia downloads photos of routes transforming images when media downloads are not configured.
+var fallbackMedia = &Media{MaxFileMB: defaultMaxFileMB, BudgetMB: defaultBudgetMB}
+
+// mediaFile returns the attachment as a Telegram file: the file ID of an earlier upload,
+// a downloaded copy when downloads are enabled, or the URL itself.
+// With images set the photo is always downloaded and transformed, and nil is returned
+// when that fails, so the original never slips through.
+func (vtCli *VTClinent) mediaFile(key, url string, images *Images) *mediaFile {
+	if images != nil {
+		key += ":" + images.id
+	}
+
+	result := &mediaFile{key: key, file: tb.FromURL(url), release: func() {}}
+
+	fileID, err := vtCli.storage.Get(fileIDKey(key))
+	if err == nil {
+		result.file = tb.File{FileID: string(fileID)}
+		result.cached = true
+
+		return result
+	}
+
+	media := vtCli.media
+	if media == nil && images != nil {
+		media = fallbackMedia
+	}
+
+	if media == nil {
+		return result
+	}
+
+	path, release, err := media.download(url)
+	if err != nil && images != nil {
+		vtCli.logger.Printf("Can't download %s, skipped: %s", key, err)
+
+		return nil
+	}
+
+	if err != nil {
+		vtCli.logger.Printf("Can't download %s, passing URL: %s", key, err)
+
+		return result
+	}
+
+	if images != nil {
+		err = images.apply(path)
+		if err != nil {
+			release()
+			vtCli.logger.Printf("Can't transform %s, skipped: %s", key, err)
+
+			return nil
+		}
+	}
+
+	result.file = tb.FromDisk(path)
+	result.release = release
+
+	return result
+}
+
+// rememberFile stores the Telegram file ID of a fresh upload for reuse in other chats.
+func (vtCli *VTClinent) rememberFile(file *mediaFile, msg *tb.Message) {
+	if file.cached || msg == nil || msg.Photo == nil || msg.Photo.FileID == "" {
+		return
+	}
+
+	err := vtCli.storage.Set(fileIDKey(file.key), []byte(msg.Photo.FileID), fileIDTTL)
+	if err != nil {
+		vtCli.logger.Printf("Can't cache file ID of %s: %s", file.key, err)
+	}
+}
+
+// sendPhoto sends a single photo, reusing or caching its Telegram file ID.
+func (vtCli *VTClinent) sendPhoto(recipient tb.Recipient, key, url string, images *Images, silent bool) {
+	if key == "" {
+		key = url
+	}
+
+	file := vtCli.mediaFile(key, url, images)
+	if file == nil {
+		return
+	}
+	defer file.release()
+
+	msg, err := vtCli.tgClient.Send(recipient, &tb.Photo{File: file.file}, &tb.SendOptions{DisableNotification: silent})
+	if err != nil {
+		vtCli.logger.Printf("Can't send photo: %s", err)
+
+		return
+	}
+
+	vtCli.rememberFile(file, msg)
+}
@@ -0,0 +1,85 @@
+package vk2tg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+)
+
+// TestMediaDownload tests the file size cap and the temp dir budget.
+func TestMediaDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		size := megabyte * 3 / 4
+		if request.URL.Path == "/large" {
+			size = 2 * megabyte
+		}
+
+		writer.Header().Set("Content-Length", strconv.Itoa(size))
+		_, _ = writer.Write([]byte(strings.Repeat("x", size)))
+	}))
+	defer server.Close()
+
+	media := &Media{MaxFileMB: 1, BudgetMB: 1, TempDir: t.TempDir()}
+
+	err := media.init()
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	_, _, err = media.download(server.URL + "/large")
+	if err == nil {
+		t.Error("expected an error for a file over the limit")
+	}
+
+	path, release, err := media.download(server.URL + "/small")
+	if err != nil {
+		t.Fatalf("failed to download: %v", err)
+	}
+
+	_, _, err = media.download(server.URL + "/small")
+	if err == nil {
+		t.Error("expected the budget to be exhausted while the first file is kept")
+	}
+
+	release()
+
+	_, err = os.Stat(path)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", path, err)
+	}
+
+	_, release, err = media.download(server.URL + "/small")
+	if err != nil {
+		t.Fatalf("expected the budget to be freed: %v", err)
+	}
+
+	release()
+}
+
+// TestPhotoKey tests that photos without a VK ID are cached by their URL.
+func TestPhotoKey(t *testing.T) {
+	photo := func(id int, url string) *vkObject.PhotosPhoto {
+		return &vkObject.PhotosPhoto{
+			ID:      id,
+			OwnerID: -5,
+			Sizes:   []vkObject.PhotosPhotoSizes{{BaseImage: vkObject.BaseImage{URL: url, Width: 1, Height: 1}}},
+		}
+	}
+
+	if photoKey(photo(0, "https://example.com/a.jpg")) == photoKey(photo(0, "https://example.com/b.jpg")) {
+		t.Error("expected different keys for photos without IDs and different URLs")
+	}
+
+	if photoKey(photo(0, "https://example.com/a.jpg")) != photoKey(photo(0, "https://example.com/a.jpg")) {
+		t.Error("expected the same key for the same URL")
+	}
+
+	if key := photoKey(photo(7, "https://example.com/a.jpg")); key != "photo-5_7" {
+		t.Errorf("expected photo-5_7, got %s", key)
+	}
+}
@@ -0,0 +1,211 @@
+package vk2tg
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// memoryStorage keeps state in process memory when Redis isn't configured.
+// Everything is lost on restart.
+type memoryStorage struct {
+	mu       sync.Mutex
+	lastPost map[int]int
+	values   map[string]memoryValue
+	lists    map[string][][]byte
+}
+
+type memoryValue struct {
+	data    []byte
+	expires time.Time
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		lastPost: make(map[int]int),
+		values:   make(map[string]memoryValue),
+		lists:    make(map[string][][]byte),
+	}
+}
+
+func (memStorage *memoryStorage) GetLastPost(ownerID int) (int, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	return memStorage.lastPost[ownerID], nil
+}
+
+func (memStorage *memoryStorage) SetLastPost(ownerID, postID int) error {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	memStorage.lastPost[ownerID] = postID
+
+	return nil
+}
+
+func (memStorage *memoryStorage) Get(key string) ([]byte, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	value, ok := memStorage.get(key)
+	if !ok {
+		return nil, errNotFound
+	}
+
+	return value, nil
+}
+
+func (memStorage *memoryStorage) GetMany(keys []string) ([][]byte, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	values := make([][]byte, len(keys))
+	for index, key := range keys {
+		values[index], _ = memStorage.get(key)
+	}
+
+	return values, nil
+}
+
+// get returns the value of key dropping it once expired, the caller holds the lock.
+func (memStorage *memoryStorage) get(key string) ([]byte, bool) {
+	value, ok := memStorage.values[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !value.expires.IsZero() && time.Now().After(value.expires) {
+		delete(memStorage.values, key)
+
+		return nil, false
+	}
+
+	return value.data, true
+}
+
+func (memStorage *memoryStorage) Set(key string, value []byte, ttl time.Duration) error {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	memStorage.set(key, value, ttl)
+
+	return nil
+}
+
+func (memStorage *memoryStorage) set(key string, value []byte, ttl time.Duration) {
+	stored := memoryValue{data: value}
+	if ttl > 0 {
+		stored.expires = time.Now().Add(ttl)
+	}
+
+	memStorage.values[key] = stored
+}
+
+func (memStorage *memoryStorage) Delete(key string) error {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	delete(memStorage.values, key)
+
+	return nil
+}
+
+func (memStorage *memoryStorage) Push(key string, value []byte, limit int) error {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	list := append([][]byte{value}, memStorage.lists[key]...)
+	if len(list) > limit {
+		list = list[:limit]
+	}
+
+	memStorage.lists[key] = list
+
+	return nil
+}
+
+func (memStorage *memoryStorage) Range(key string, count int) ([][]byte, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	list := memStorage.lists[key]
+	if count > 0 && len(list) > count {
+		list = list[:count]
+	}
+
+	return slices.Clone(list), nil
+}
+
+func (memStorage *memoryStorage) Acquire(key string, value []byte, ttl time.Duration) (bool, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	if _, ok := memStorage.get(key); ok {
+		return false, nil
+	}
+
+	memStorage.set(key, value, ttl)
+
+	return true, nil
+}
+
+func (memStorage *memoryStorage) Renew(key string, value []byte, ttl time.Duration) (bool, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	current, ok := memStorage.get(key)
+	if !ok || !bytes.Equal(current, value) {
+		return false, nil
+	}
+
+	memStorage.set(key, value, ttl)
+
+	return true, nil
+}
+
+func (memStorage *memoryStorage) Release(key string, value []byte) error {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	if current, ok := memStorage.get(key); ok && bytes.Equal(current, value) {
+		delete(memStorage.values, key)
+	}
+
+	return nil
+}
+
+func (memStorage *memoryStorage) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	memStorage.mu.Lock()
+	defer memStorage.mu.Unlock()
+
+	var counter int64
+
+	current, ok := memStorage.get(key)
+	if ok {
+		var err error
+
+		counter, err = strconv.ParseInt(string(current), 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't increment %s", key)
+		}
+	}
+
+	counter += delta
+
+	if !ok {
+		memStorage.set(key, []byte(strconv.FormatInt(counter, 10)), ttl)
+
+		return counter, nil
+	}
+
+	stored := memStorage.values[key]
+	stored.data = []byte(strconv.FormatInt(counter, 10))
+	memStorage.values[key] = stored
+
+	return counter, nil
+}
@@ -0,0 +1,346 @@
+package vk2tg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// Callback endpoints of the moderation buttons.
+const (
+	approveUnique = "mod_approve"
+	rejectUnique  = "mod_reject"
+	editUnique    = "mod_edit"
+)
+
+const (
+	// defaultModerationExpire is how long a post waits for a decision by default.
+	defaultModerationExpire = 24 * time.Hour
+	// moderationIDBytes is the size of a random pending post ID, kept short for callback data.
+	moderationIDBytes = 8
+)
+
+// Moderation sends route posts to an admin chat for approval before publishing.
+type Moderation struct {
+	ChatID int64         `yaml:"chatId"`
+	Expire time.Duration `yaml:"expire"`
+}
+
+// pendingPost is a post waiting for a moderator decision as persisted in storage.
+type pendingPost struct {
+	ID               string                 `json:"id"`
+	Route            string                 `json:"route"`
+	Post             *vkObject.WallWallpost `json:"post"`
+	PreviewChatID    int64                  `json:"previewChatId"`
+	PreviewMessageID int                    `json:"previewMessageId"`
+	Expires          time.Time              `json:"expires"`
+}
+
+func (moderation *Moderation) init() error {
+	if moderation.ChatID == 0 {
+		return errors.New("admin chat ID is required")
+	}
+
+	if moderation.Expire == 0 {
+		moderation.Expire = defaultModerationExpire
+	}
+
+	if moderation.Expire < 0 {
+		return errors.New("expire must be positive")
+	}
+
+	return nil
+}
+
+func pendingKey(id string) string {
+	return "moderation:" + id
+}
+
+func promptKey(chatID int64, messageID int) string {
+	return "moderation-prompt:" + strconv.FormatInt(chatID, 10) + ":" + strconv.Itoa(messageID)
+}
+
+func (vtCli *VTClinent) savePending(pending *pendingPost) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return errors.Wrap(err, "can't encode pending post")
+	}
+
+	return vtCli.storage.Set(pendingKey(pending.ID), data, time.Until(pending.Expires))
+}
+
+func (vtCli *VTClinent) loadPending(id string) (*pendingPost, error) {
+	data, err := vtCli.storage.Get(pendingKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	pending := new(pendingPost)
+
+	err = json.Unmarshal(data, pending)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode pending post")
+	}
+
+	return pending, nil
+}
+
+// submitForModeration sends a preview of the post to the moderators and keeps it until a decision.
+func (vtCli *VTClinent) submitForModeration(route *Route, post *vkObject.WallWallpost) error {
+	idBytes := make([]byte, moderationIDBytes)
+
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return errors.Wrap(err, "can't generate ID")
+	}
+
+	pending := &pendingPost{
+		ID:      hex.EncodeToString(idBytes),
+		Route:   route.Name,
+		Post:    post,
+		Expires: time.Now().Add(route.Moderation.Expire),
+	}
+
+	recipient := tb.ChatID(route.Moderation.ChatID)
+	lang := vtCli.chatLanguage(route.Moderation.ChatID)
+
+	vtCli.sendPhotos(route, recipient, post, false)
+
+	msg, err := vtCli.tgClient.Send(recipient, vtCli.previewText(pending, "", lang), moderationMarkup(pending, lang))
+	if err != nil {
+		return errors.Wrap(err, "can't send preview")
+	}
+
+	pending.PreviewChatID = msg.Chat.ID
+	pending.PreviewMessageID = msg.ID
+
+	return vtCli.savePending(pending)
+}
+
+func (vtCli *VTClinent) previewText(pending *pendingPost, verdict, lang string) string {
+	text := translate(lang, "moderation.preview",
+		pending.Route,
+		vtCli.sourceName(pending.Post.OwnerID),
+		postURL(pending.Post),
+		pending.Post.Text,
+	)
+
+	if verdict != "" {
+		text += "\n\n" + verdict
+	}
+
+	return text
+}
+
+func moderationMarkup(pending *pendingPost, lang string) *tb.ReplyMarkup {
+	return &tb.ReplyMarkup{
+		InlineKeyboard: [][]tb.InlineButton{
+			{
+				{Unique: approveUnique, Text: translate(lang, "moderation.approve"), Data: pending.ID},
+				{Unique: rejectUnique, Text: translate(lang, "moderation.reject"), Data: pending.ID},
+			},
+			{
+				{Unique: editUnique, Text: translate(lang, "moderation.edit"), Data: pending.ID},
+			},
+		},
+	}
+}
+
+// callbackPending loads the pending post a moderation button refers to.
+// It returns nil after answering the callback when the post can't be handled.
+func (vtCli *VTClinent) callbackPending(tbContext tb.Context) (*pendingPost, *Route, error) {
+	if !vtCli.isAdmin(tbContext.Sender()) {
+		return nil, nil, respond(tbContext, vtCli.tr(tbContext, "moderation.adminsOnly"))
+	}
+
+	pending, err := vtCli.loadPending(tbContext.Callback().Data)
+	if errors.Is(err, errNotFound) {
+		return nil, nil, respond(tbContext, vtCli.tr(tbContext, "moderation.expired"))
+	}
+
+	if err != nil {
+		vtCli.logger.Printf("Can't load pending post: %s", err)
+
+		return nil, nil, respond(tbContext, vtCli.tr(tbContext, "moderation.loadError"))
+	}
+
+	route := vtCli.route(pending.Route)
+	if route == nil {
+		return nil, nil, respond(tbContext, vtCli.tr(tbContext, "moderation.noRoute"))
+	}
+
+	return pending, route, nil
+}
+
+// closePreview marks the preview with the decision and removes its buttons.
+func (vtCli *VTClinent) closePreview(pending *pendingPost, verdict localized) {
+	lang := vtCli.chatLanguage(pending.PreviewChatID)
+
+	_, err := vtCli.tgClient.Edit(
+		&tb.StoredMessage{MessageID: strconv.Itoa(pending.PreviewMessageID), ChatID: pending.PreviewChatID},
+		vtCli.previewText(pending, verdict.in(lang), lang),
+		&tb.ReplyMarkup{},
+	)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't update preview: %s", pending.Post.ID, err)
+	}
+}
+
+func (vtCli *VTClinent) moderationApprove(tbContext tb.Context) error {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	pending, route, err := vtCli.callbackPending(tbContext)
+	if pending == nil {
+		return err
+	}
+
+	err = vtCli.storage.Delete(pendingKey(pending.ID))
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't remove pending post: %s", pending.Post.ID, err)
+	}
+
+	vtCli.logger.Printf("Post %d: Approved by %d for route %s", pending.Post.ID, tbContext.Sender().ID, route.Name)
+
+	vtCli.closePreview(pending, localized{"moderation.approvedBy", []any{displayName(tbContext.Sender())}})
+
+	go func() {
+		// The preview is closed already, so a post the leader can't publish any more goes back to moderation.
+		if !vtCli.fenced() {
+			vtCli.logger.Printf("Post %d: Leader lease lost, submitted for moderation again", pending.Post.ID)
+
+			err := vtCli.submitForModeration(route, pending.Post)
+			if err != nil {
+				vtCli.logger.Printf("Post %d: Can't submit for moderation in route %s: %s", pending.Post.ID, route.Name, err)
+			}
+
+			return
+		}
+
+		deliveries, _ := vtCli.publish(route, pending.Post, nil)
+		if len(deliveries) > 0 {
+			vtCli.archivePost(pending.Post, deliveries)
+		}
+	}()
+
+	return respond(tbContext, vtCli.tr(tbContext, "moderation.approved"))
+}
+
+func (vtCli *VTClinent) moderationReject(tbContext tb.Context) error {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	pending, _, err := vtCli.callbackPending(tbContext)
+	if pending == nil {
+		return err
+	}
+
+	err = vtCli.storage.Delete(pendingKey(pending.ID))
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't remove pending post: %s", pending.Post.ID, err)
+	}
+
+	vtCli.logger.Printf("Post %d: Rejected by %d", pending.Post.ID, tbContext.Sender().ID)
+
+	vtCli.closePreview(pending, localized{"moderation.rejectedBy", []any{displayName(tbContext.Sender())}})
+
+	return respond(tbContext, vtCli.tr(tbContext, "moderation.rejected"))
+}
+
+// moderationEdit asks the moderator for a new caption, which is expected as a reply to the prompt.
+func (vtCli *VTClinent) moderationEdit(tbContext tb.Context) error {
+	pending, _, err := vtCli.callbackPending(tbContext)
+	if pending == nil {
+		return err
+	}
+
+	prompt, err := vtCli.tgClient.Send(
+		tbContext.Chat(),
+		vtCli.tr(tbContext, "moderation.prompt"),
+		&tb.SendOptions{ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true}},
+	)
+	if err != nil {
+		return errors.Wrap(err, "error on sending message")
+	}
+
+	err = vtCli.storage.Set(promptKey(prompt.Chat.ID, prompt.ID), []byte(pending.ID), time.Until(pending.Expires))
+	if err != nil {
+		vtCli.logger.Printf("Can't save caption prompt: %s", err)
+	}
+
+	return respond(tbContext, "")
+}
+
+// editCaption replaces the text of a pending post if msg answers a caption prompt.
+// It reports whether msg was such an answer.
+func (vtCli *VTClinent) editCaption(tbContext tb.Context) (bool, error) {
+	msg := tbContext.Message()
+	if msg.ReplyTo == nil || !vtCli.isAdmin(tbContext.Sender()) {
+		return false, nil
+	}
+
+	id, err := vtCli.storage.Get(promptKey(msg.Chat.ID, msg.ReplyTo.ID))
+	if err != nil {
+		return false, nil //nolint:nilerr // not a caption prompt
+	}
+
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	pending, err := vtCli.loadPending(string(id))
+	if err != nil {
+		return true, errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "moderation.expired")), "error on sending message")
+	}
+
+	pending.Post.Text = msg.Text
+
+	err = vtCli.savePending(pending)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't save caption: %s", pending.Post.ID, err)
+
+		return true, errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "moderation.saveError")), "error on sending message")
+	}
+
+	lang := vtCli.chatLanguage(pending.PreviewChatID)
+
+	_, err = vtCli.tgClient.Edit(
+		&tb.StoredMessage{MessageID: strconv.Itoa(pending.PreviewMessageID), ChatID: pending.PreviewChatID},
+		vtCli.previewText(pending, "", lang),
+		moderationMarkup(pending, lang),
+	)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't update preview: %s", pending.Post.ID, err)
+	}
+
+	return true, errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "moderation.updated")), "error on sending message")
+}
+
+// onText handles plain text messages: replies to bot prompts.
+func (vtCli *VTClinent) onText(tbContext tb.Context) error {
+	_, err := vtCli.editCaption(tbContext)
+
+	return err
+}
+
+func respond(tbContext tb.Context, text string) error {
+	err := tbContext.Respond(&tb.CallbackResponse{Text: text})
+	if err != nil {
+		return errors.Wrap(err, "error on answering callback")
+	}
+
+	return nil
+}
+
+func displayName(user *tb.User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+
+	return user.FirstName
+}
@@ -0,0 +1,174 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	"github.com/cockroachdb/errors"
+)
+
+// nameCacheTTL is how long resolved names are kept before asking VK again.
+const nameCacheTTL = 7 * 24 * time.Hour
+
+// profile is a VK user (positive ID) or community (negative ID) as shown in messages.
+type profile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func nameKey(id int) string {
+	return "name:" + strconv.Itoa(id)
+}
+
+// fallbackProfile is used until the name is resolved: "id1" for users and "club1" for communities.
+func fallbackProfile(id int) profile {
+	name := "id" + strconv.Itoa(id)
+	if id < 0 {
+		name = "club" + strconv.Itoa(-id)
+	}
+
+	return profile{ID: id, Name: name, URL: "https://vk.com/" + name}
+}
+
+func newProfile(id int, name, screenName string) profile {
+	result := fallbackProfile(id)
+
+	if name = strings.TrimSpace(name); name != "" {
+		result.Name = name
+	}
+
+	if screenName != "" {
+		result.URL = "https://vk.com/" + screenName
+	}
+
+	return result
+}
+
+func (vtCli *VTClinent) cachedProfile(id int) (profile, bool) {
+	var cached profile
+
+	data, err := vtCli.storage.Get(nameKey(id))
+	if err != nil {
+		return cached, false
+	}
+
+	err = json.Unmarshal(data, &cached)
+	if err != nil {
+		return cached, false
+	}
+
+	return cached, true
+}
+
+func (vtCli *VTClinent) cacheProfile(resolved profile) {
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		vtCli.logger.Printf("Can't encode name of %d: %s", resolved.ID, err)
+
+		return
+	}
+
+	err = vtCli.storage.Set(nameKey(resolved.ID), data, nameCacheTTL)
+	if err != nil {
+		vtCli.logger.Printf("Can't cache name of %d: %s", resolved.ID, err)
+	}
+}
+
+// resolveNames returns profiles of the given users and communities.
+// Cache misses are resolved with a single users.get and groups.getById call,
+// IDs VK can't resolve keep their numeric names.
+func (vtCli *VTClinent) resolveNames(ids ...int) map[int]profile {
+	profiles := make(map[int]profile, len(ids))
+
+	var userIDs, groupIDs []string
+
+	for _, id := range ids {
+		if _, ok := profiles[id]; ok || id == 0 {
+			continue
+		}
+
+		if isFeedOwner(id) {
+			profiles[id] = vtCli.feedProfile(id)
+
+			continue
+		}
+
+		if cached, ok := vtCli.cachedProfile(id); ok {
+			profiles[id] = cached
+
+			continue
+		}
+
+		profiles[id] = fallbackProfile(id)
+
+		if id > 0 {
+			userIDs = append(userIDs, strconv.Itoa(id))
+		} else {
+			groupIDs = append(groupIDs, strconv.Itoa(-id))
+		}
+	}
+
+	resolved, err := vtCli.fetchNames(userIDs, groupIDs)
+	if err != nil {
+		vtCli.logger.Printf("Can't resolve names: %s", err)
+	}
+
+	for _, found := range resolved {
+		profiles[found.ID] = found
+		vtCli.cacheProfile(found)
+	}
+
+	return profiles
+}
+
+// fetchNames asks VK for names of users and communities, returning whatever was resolved before an error.
+func (vtCli *VTClinent) fetchNames(userIDs, groupIDs []string) ([]profile, error) {
+	var resolved []profile
+
+	// Simulations may run without VK access.
+	if vtCli.vkClient == nil {
+		return nil, nil
+	}
+
+	if len(userIDs) > 0 {
+		users, err := vtCli.vkClient.UsersGet(vkapi.Params{
+			"user_ids": strings.Join(userIDs, ","),
+			"fields":   "screen_name",
+		})
+		if err != nil {
+			return resolved, errors.Wrap(err, "failed to fetch users")
+		}
+
+		for index := range users {
+			user := &users[index]
+			resolved = append(resolved, newProfile(user.ID, user.FirstName+" "+user.LastName, user.ScreenName))
+		}
+	}
+
+	if len(groupIDs) > 0 {
+		response, err := vtCli.vkClient.GroupsGetByID(vkapi.Params{"group_ids": strings.Join(groupIDs, ",")})
+		if err != nil {
+			return resolved, errors.Wrap(err, "failed to fetch communities")
+		}
+
+		for index := range response.Groups {
+			group := &response.Groups[index]
+			resolved = append(resolved, newProfile(-group.ID, group.Name, group.ScreenName))
+		}
+	}
+
+	return resolved, nil
+}
+
+// resolveName returns the profile of a single user or community.
+func (vtCli *VTClinent) resolveName(id int) profile {
+	if id == 0 {
+		return profile{}
+	}
+
+	return vtCli.resolveNames(id)[id]
+}
@@ -0,0 +1,220 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+)
+
+// queueMaxAttempts is how many times a queued post is sent before it is dropped.
+const queueMaxAttempts = 5
+
+// postQueue is a list of posts a route sends later, held by quiet hours or queued by a schedule,
+// as persisted in storage. The post being sent stays in the list marked in flight until it is
+// finished, so a restart neither loses it nor sends it twice once it is archived.
+type postQueue struct {
+	LastSent time.Time    `json:"lastSent"`
+	Items    []queuedPost `json:"items"`
+	// InFlight is the "<owner>_<post>" reference of the post being sent
+	InFlight string `json:"inFlight,omitempty"`
+}
+
+type queuedPost struct {
+	Post *vkObject.WallWallpost `json:"post"`
+	Due  time.Time              `json:"due"`
+	// Delivered lists the targets earlier attempts reached, retries skip them
+	Delivered []sentMessage `json:"delivered,omitempty"`
+	Attempts  int           `json:"attempts,omitempty"`
+}
+
+func (vtCli *VTClinent) loadPostQueue(key string) (*postQueue, error) {
+	data, err := vtCli.storage.Get(key)
+	if errors.Is(err, errNotFound) {
+		return &postQueue{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	queue := new(postQueue)
+
+	err = json.Unmarshal(data, queue)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't decode %s", key)
+	}
+
+	return queue, nil
+}
+
+func (vtCli *VTClinent) savePostQueue(key string, queue *postQueue) error {
+	data, err := json.Marshal(queue)
+	if err != nil {
+		return errors.Wrapf(err, "can't encode %s", key)
+	}
+
+	return vtCli.storage.Set(key, data, 0)
+}
+
+// pushPost appends the post to the queue under key.
+func (vtCli *VTClinent) pushPost(key string, item queuedPost) error {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	queue, err := vtCli.loadPostQueue(key)
+	if err != nil {
+		return err
+	}
+
+	queue.Items = append(queue.Items, item)
+
+	return vtCli.savePostQueue(key, queue)
+}
+
+// takePost marks the first post of the queue under key that is ready in flight and returns it.
+// A post left in flight by a crash is resolved against the archive first.
+func (vtCli *VTClinent) takePost(route *Route, key string, ready func(queue *postQueue, item *queuedPost) bool) *queuedPost {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	queue, err := vtCli.loadPostQueue(key)
+	if err != nil {
+		vtCli.logger.Printf("Can't load %s: %s", key, err)
+
+		return nil
+	}
+
+	if queue.InFlight != "" {
+		err = vtCli.resolveInFlight(route, key, queue)
+		if err != nil {
+			vtCli.logger.Printf("Can't resolve post %s in flight in %s: %s", queue.InFlight, key, err)
+
+			return nil
+		}
+	}
+
+	for index := range queue.Items {
+		item := &queue.Items[index]
+
+		if ready != nil && !ready(queue, item) {
+			continue
+		}
+
+		if !vtCli.fenced() {
+			vtCli.logger.Printf("Post %d: Leader lease lost, left in %s", item.Post.ID, key)
+
+			return nil
+		}
+
+		queue.InFlight = postRef(item.Post)
+
+		err = vtCli.savePostQueue(key, queue)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't save %s: %s", item.Post.ID, key, err)
+
+			return nil
+		}
+
+		return item
+	}
+
+	return nil
+}
+
+// finishPost records the outcome of sending a post taken off the queue under key.
+// A failed post stays queued for another attempt with the targets it reached,
+// a delivered one, or one out of attempts, is archived and removed.
+func (vtCli *VTClinent) finishPost(route *Route, key string, item *queuedPost, deliveries []sentMessage, sendErr error) {
+	vtCli.stateMu.Lock()
+	defer vtCli.stateMu.Unlock()
+
+	queue, err := vtCli.loadPostQueue(key)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't load %s: %s", item.Post.ID, key, err)
+
+		return
+	}
+
+	ref := postRef(item.Post)
+	deliveries = append(slices.Clip(item.Delivered), deliveries...)
+
+	switch {
+	case sendErr == nil:
+		queue.LastSent = time.Now()
+	case item.Attempts+1 >= queueMaxAttempts:
+		vtCli.logger.Printf("Post %d: Dropped from %s after %d attempts", item.Post.ID, key, queueMaxAttempts)
+	default:
+		index := slices.IndexFunc(queue.Items, func(queued queuedPost) bool { return postRef(queued.Post) == ref })
+		if index >= 0 {
+			queue.Items[index].Delivered = deliveries
+			queue.Items[index].Attempts++
+		}
+
+		queue.InFlight = ""
+
+		vtCli.logger.Printf("Post %d: Left in %s for another attempt", item.Post.ID, key)
+
+		err = vtCli.savePostQueue(key, queue)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't save %s: %s", item.Post.ID, key, err)
+		}
+
+		return
+	}
+
+	// The archive is what tells a restart that the post was delivered, so it goes first.
+	if len(deliveries) > 0 {
+		vtCli.archivePost(item.Post, deliveries)
+	}
+
+	queue.remove(ref)
+
+	err = vtCli.savePostQueue(key, queue)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't save %s: %s", item.Post.ID, key, err)
+	}
+}
+
+// resolveInFlight settles a post a crashed leader was sending, the caller holds stateMu.
+// A post archived as delivered via the route is dropped, any other is sent again.
+func (vtCli *VTClinent) resolveInFlight(route *Route, key string, queue *postQueue) error {
+	ownerID, postID, err := parsePostRef(queue.InFlight)
+	if err != nil {
+		return err
+	}
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		return err
+	}
+
+	delivered := slices.ContainsFunc(posts, func(post *archivedPost) bool {
+		return post.PostID == postID && post.OwnerID == ownerID &&
+			slices.ContainsFunc(post.Deliveries, func(delivery sentMessage) bool { return delivery.Route == route.Name })
+	})
+
+	if delivered {
+		vtCli.logger.Printf("Post %d: Was delivered via route %s before restart, dropped from %s", postID, route.Name, key)
+
+		queue.remove(queue.InFlight)
+		queue.LastSent = time.Now()
+	} else {
+		vtCli.logger.Printf("Post %d: Wasn't delivered via route %s before restart, left in %s", postID, route.Name, key)
+
+		queue.InFlight = ""
+	}
+
+	return vtCli.savePostQueue(key, queue)
+}
+
+// remove drops the post from the queue and clears its in flight mark.
+func (queue *postQueue) remove(ref string) {
+	queue.Items = slices.DeleteFunc(queue.Items, func(item queuedPost) bool { return postRef(item.Post) == ref })
+
+	if queue.InFlight == ref {
+		queue.InFlight = ""
+	}
+}
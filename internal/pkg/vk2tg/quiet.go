@@ -0,0 +1,209 @@
+package vk2tg
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+)
+
+// Quiet hours modes.
+const (
+	// QuietModeSilent sends posts without a notification sound.
+	QuietModeSilent = "silent"
+	// QuietModeHold keeps posts until the quiet window is over.
+	// Held posts are kept in storage, so they survive a restart when Redis is used.
+	QuietModeHold = "hold"
+)
+
+// QuietHours is a daily window in which a route doesn't disturb its subscribers.
+type QuietHours struct {
+	Start    string                  `yaml:"start"`
+	End      string                  `yaml:"end"`
+	Timezone string                  `yaml:"timezone"`
+	Mode     string                  `yaml:"mode"`
+	Weekdays map[string]*QuietWindow `yaml:"weekdays"`
+
+	location *time.Location
+	window   QuietWindow
+}
+
+// QuietWindow overrides the quiet hours for a single weekday.
+type QuietWindow struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	Off   bool   `yaml:"off"`
+
+	startMinute int
+	endMinute   int
+}
+
+// init validates the schedule and resolves its timezone, Moscow time by default.
+func (quiet *QuietHours) init() error {
+	var err error
+
+	quiet.location = zone
+
+	if quiet.Timezone != "" {
+		quiet.location, err = time.LoadLocation(quiet.Timezone)
+		if err != nil {
+			return errors.Wrap(err, "unknown timezone")
+		}
+	}
+
+	switch quiet.Mode {
+	case "":
+		quiet.Mode = QuietModeSilent
+	case QuietModeSilent, QuietModeHold:
+	default:
+		return errors.Newf("unknown mode %q", quiet.Mode)
+	}
+
+	quiet.window = QuietWindow{Start: quiet.Start, End: quiet.End}
+
+	err = quiet.window.init()
+	if err != nil {
+		return err
+	}
+
+	for day, window := range quiet.Weekdays {
+		if !slices.Contains(weekdayNames, day) {
+			return errors.Newf("unknown weekday %q", day)
+		}
+
+		if window == nil {
+			return errors.Newf("weekday %s has no window, set start and end or off", day)
+		}
+
+		if window.Off {
+			continue
+		}
+
+		err = window.init()
+		if err != nil {
+			return errors.Wrapf(err, "weekday %s", day)
+		}
+	}
+
+	return nil
+}
+
+func (window *QuietWindow) init() error {
+	var err error
+
+	window.startMinute, err = parseClock(window.Start)
+	if err != nil {
+		return errors.Wrap(err, "invalid start")
+	}
+
+	window.endMinute, err = parseClock(window.End)
+	if err != nil {
+		return errors.Wrap(err, "invalid end")
+	}
+
+	return nil
+}
+
+// ActiveUntil reports whether now falls into the quiet hours and when they end.
+func (quiet *QuietHours) ActiveUntil(now time.Time) (time.Time, bool) {
+	local := now.In(quiet.location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, quiet.location)
+
+	// A window that started yesterday may still be running after midnight.
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		start, end, ok := quiet.windowFor(day)
+		if ok && !local.Before(start) && local.Before(end) {
+			return end, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// windowFor returns the quiet window that starts on the given day.
+func (quiet *QuietHours) windowFor(day time.Time) (time.Time, time.Time, bool) {
+	window := &quiet.window
+
+	if override, ok := quiet.Weekdays[strings.ToLower(day.Weekday().String())]; ok {
+		if override.Off {
+			return time.Time{}, time.Time{}, false
+		}
+
+		window = override
+	}
+
+	if window.startMinute == window.endMinute {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, window.startMinute, 0, 0, quiet.location)
+	end := time.Date(day.Year(), day.Month(), day.Day(), 0, window.endMinute, 0, 0, quiet.location)
+
+	if window.endMinute < window.startMinute {
+		end = time.Date(day.Year(), day.Month(), day.Day()+1, 0, window.endMinute, 0, 0, quiet.location)
+	}
+
+	return start, end, true
+}
+
+// String describes the schedule for the /status command.
+func (quiet *QuietHours) String() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "%s–%s %s, %s", quiet.Start, quiet.End, quiet.location, quiet.Mode)
+
+	for _, day := range weekdayNames {
+		window, ok := quiet.Weekdays[day]
+		if !ok {
+			continue
+		}
+
+		if window.Off {
+			fmt.Fprintf(&builder, "; %s: off", day)
+		} else {
+			fmt.Fprintf(&builder, "; %s: %s–%s", day, window.Start, window.End)
+		}
+	}
+
+	return builder.String()
+}
+
+func heldKey(route *Route) string {
+	return "held:" + route.Name
+}
+
+// holdPost keeps the post until the quiet hours of the route are over.
+func (vtCli *VTClinent) holdPost(route *Route, post *vkObject.WallWallpost) error {
+	return vtCli.pushPost(heldKey(route), queuedPost{Post: post, Due: time.Now()})
+}
+
+var weekdayNames = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// parseClock converts "HH:MM" to minutes since midnight.
+func parseClock(clock string) (int, error) {
+	hours, minutes, found := strings.Cut(clock, ":")
+	if !found {
+		return 0, errors.Newf("%q is not in HH:MM format", clock)
+	}
+
+	hour, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, errors.Wrapf(err, "bad hour in %q", clock)
+	}
+
+	minute, err := strconv.Atoi(minutes)
+	if err != nil {
+		return 0, errors.Wrapf(err, "bad minute in %q", clock)
+	}
+
+	//nolint:mnd // clock bounds
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, errors.Newf("%q is out of range", clock)
+	}
+
+	return hour*60 + minute, nil
+}
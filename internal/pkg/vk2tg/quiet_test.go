@@ -0,0 +1,218 @@
+package vk2tg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestQuietHoursActiveUntil tests overnight windows and weekday overrides.
+func TestQuietHoursActiveUntil(t *testing.T) {
+	quiet := &QuietHours{
+		Start:    "23:00",
+		End:      "08:00",
+		Timezone: "Europe/Moscow",
+		Weekdays: map[string]*QuietWindow{
+			"saturday": {Start: "01:00", End: "11:00"},
+			"sunday":   {Off: true},
+		},
+	}
+
+	err := quiet.init()
+	if err != nil {
+		t.Fatalf("failed to init quiet hours: %v", err)
+	}
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+		until    time.Time
+	}{
+		{
+			name:     "weekday evening before the window",
+			now:      time.Date(2026, 10, 14, 22, 59, 0, 0, moscow),
+			expected: false,
+		},
+		{
+			name:     "weekday late evening",
+			now:      time.Date(2026, 10, 14, 23, 30, 0, 0, moscow),
+			expected: true,
+			until:    time.Date(2026, 10, 15, 8, 0, 0, 0, moscow),
+		},
+		{
+			name:     "after midnight of a window started yesterday",
+			now:      time.Date(2026, 10, 15, 7, 59, 0, 0, moscow),
+			expected: true,
+			until:    time.Date(2026, 10, 15, 8, 0, 0, 0, moscow),
+		},
+		{
+			name:     "window end is exclusive",
+			now:      time.Date(2026, 10, 15, 8, 0, 0, 0, moscow),
+			expected: false,
+		},
+		{
+			name:     "saturday override",
+			now:      time.Date(2026, 10, 17, 10, 0, 0, 0, moscow),
+			expected: true,
+			until:    time.Date(2026, 10, 17, 11, 0, 0, 0, moscow),
+		},
+		{
+			name:     "sunday is off",
+			now:      time.Date(2026, 10, 18, 23, 30, 0, 0, moscow),
+			expected: false,
+		},
+		{
+			name:     "other timezone input",
+			now:      time.Date(2026, 10, 14, 20, 30, 0, 0, time.UTC),
+			expected: true,
+			until:    time.Date(2026, 10, 15, 8, 0, 0, 0, moscow),
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			until, active := quiet.ActiveUntil(testCase.now)
+
+			if active != testCase.expected {
+				t.Fatalf("expected active=%t, got %t", testCase.expected, active)
+			}
+
+			if active && !until.Equal(testCase.until) {
+				t.Errorf("expected end %s, got %s", testCase.until, until)
+			}
+		})
+	}
+}
+
+// TestParseClock tests HH:MM parsing.
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		wantErr  bool
+	}{
+		{input: "00:00", expected: 0},
+		{input: "08:30", expected: 510},
+		{input: "23:59", expected: 1439},
+		{input: "24:00", wantErr: true},
+		{input: "8", wantErr: true},
+		{input: "aa:10", wantErr: true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.input, func(t *testing.T) {
+			result, err := parseClock(testCase.input)
+
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+
+			if result != testCase.expected {
+				t.Errorf("expected %d, got %d", testCase.expected, result)
+			}
+		})
+	}
+}
+
+// TestQuietHoursEmptyWeekday tests that a weekday without a window is rejected instead of panicking.
+func TestQuietHoursEmptyWeekday(t *testing.T) {
+	quiet := &QuietHours{Start: "23:00", End: "08:00", Weekdays: map[string]*QuietWindow{"sunday": nil}}
+
+	err := quiet.init()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// TestQuietHoursDefaultTimezone tests that quiet hours without a timezone use Moscow time.
+func TestQuietHoursDefaultTimezone(t *testing.T) {
+	quiet := &QuietHours{Start: "23:00", End: "08:00"}
+
+	err := quiet.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, active := quiet.ActiveUntil(time.Date(2026, 10, 14, 20, 30, 0, 0, time.UTC)); !active {
+		t.Error("expected 23:30 Moscow time to be quiet")
+	}
+}
+
+// TestHeldPosts tests that held posts stay in storage until they are delivered.
+func TestHeldPosts(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+
+		if calls.Add(1) == 1 {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+
+			return
+		}
+
+		_, _ = writer.Write([]byte(`{"ok":true,"result":{"message_id":7,"chat":{"id":-100}}}`))
+	}))
+	defer server.Close()
+
+	bot, err := tb.NewBot(tb.Settings{Token: "token", URL: server.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Quiet hours of zero length are never active, so held posts are due right away.
+	route := &Route{Name: "night", ChatID: -100, QuietHours: &QuietHours{Start: "00:00", End: "00:00", Mode: QuietModeHold}}
+
+	err = route.QuietHours.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.tgClient = bot
+	vtCli.routes = []*Route{route}
+	vtCli.webhook = &tb.Webhook{}
+	vtCli.campaign()
+
+	for _, id := range []int{1, 2} {
+		err = vtCli.holdPost(route, &vkObject.WallWallpost{ID: id, OwnerID: -5, Text: "post"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vtCli.releaseHeld()
+
+	held, err := vtCli.loadPostQueue(heldKey(route))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(held.Items) != 2 || held.Items[0].Attempts != 1 || held.InFlight != "" {
+		t.Fatalf("expected both posts kept after a failure, got %+v", held)
+	}
+
+	vtCli.releaseHeld()
+
+	held, err = vtCli.loadPostQueue(heldKey(route))
+	if err != nil || len(held.Items) != 0 {
+		t.Fatalf("expected nothing left, got %+v, %v", held, err)
+	}
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil || len(posts) != 2 || posts[1].PostID != 1 {
+		t.Errorf("expected both posts archived in order, got %+v, %v", posts, err)
+	}
+}
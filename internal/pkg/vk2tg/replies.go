@@ -0,0 +1,117 @@
+package vk2tg
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// VKReplies lets users comment on VK posts by replying to forwarded messages with /comment.
+type VKReplies struct {
+	// Token is a VK user or community token allowed to comment.
+	Token string `yaml:"token"`
+	// GroupID posts comments on behalf of the community, it needs a token of its admin.
+	GroupID int `yaml:"groupId"`
+	// Users may comment besides the bot admins.
+	Users []int64 `yaml:"users"`
+
+	client *vkapi.VK
+}
+
+func (replies *VKReplies) init() error {
+	if replies.Token == "" {
+		return errors.New("VK token is required")
+	}
+
+	if replies.GroupID < 0 {
+		return errors.New("group ID must be positive")
+	}
+
+	replies.client = vkapi.NewVK(replies.Token)
+
+	return nil
+}
+
+// commentURL links to a comment on the post.
+func commentURL(post *archivedPost, commentID int) string {
+	return post.url() + "?reply=" + strconv.Itoa(commentID)
+}
+
+// repliedPost finds the archived post the message was forwarded as.
+func (vtCli *VTClinent) repliedPost(msg *tb.Message) (*archivedPost, error) {
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		for _, delivery := range post.Deliveries {
+			if delivery.ChatID == msg.Chat.ID && delivery.MessageID == msg.ID {
+				return post, nil
+			}
+		}
+	}
+
+	return nil, errNotFound
+}
+
+func (vtCli *VTClinent) canComment(user *tb.User) bool {
+	return vtCli.isAdmin(user) || (user != nil && slices.Contains(vtCli.vkReplies.Users, user.ID))
+}
+
+// comment posts the text as a comment on the VK post the message replies to.
+func (vtCli *VTClinent) comment(tbContext tb.Context) error {
+	if vtCli.vkReplies == nil || !vtCli.canComment(tbContext.Sender()) {
+		return nil
+	}
+
+	msg := tbContext.Message()
+
+	text := strings.TrimSpace(msg.Payload)
+	if msg.ReplyTo == nil || text == "" {
+		return errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "comment.usage")), "error on sending message")
+	}
+
+	post, err := vtCli.repliedPost(msg.ReplyTo)
+	if errors.Is(err, errNotFound) {
+		return errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "comment.notPost")), "error on sending message")
+	}
+
+	if err != nil {
+		vtCli.logger.Printf("Can't read archive: %s", err)
+
+		return errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "archive.error")), "error on sending message")
+	}
+
+	if isFeedOwner(post.OwnerID) {
+		return errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "comment.feed")), "error on sending message")
+	}
+
+	params := vkapi.Params{
+		"owner_id": post.OwnerID,
+		"post_id":  post.PostID,
+		"message":  text,
+	}
+
+	if vtCli.vkReplies.GroupID != 0 {
+		params["from_group"] = vtCli.vkReplies.GroupID
+	}
+
+	response, err := vtCli.vkReplies.client.WallCreateComment(params)
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't comment for %d: %s", post.PostID, tbContext.Sender().ID, err)
+
+		return errors.Wrap(tbContext.Reply(vtCli.tr(tbContext, "comment.refused", err)), "error on sending message")
+	}
+
+	vtCli.logger.Printf("Post %d: Comment %d added by %d", post.PostID, response.CommentID, tbContext.Sender().ID)
+
+	return errors.Wrap(
+		tbContext.Reply(vtCli.tr(tbContext, "comment.done", commentURL(post, response.CommentID)), &tb.SendOptions{DisableWebPagePreview: true}),
+		"error on sending message",
+	)
+}
@@ -0,0 +1,54 @@
+package vk2tg
+
+import (
+	"strconv"
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestRepliedPost tests that a reply is traced back to the VK post through the archive.
+func TestRepliedPost(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.archivePost(&vkObject.WallWallpost{ID: 7, OwnerID: -5}, []sentMessage{{Route: "a", ChatID: -100, MessageID: 40}})
+	vtCli.archivePost(&vkObject.WallWallpost{ID: 8, OwnerID: -5}, []sentMessage{{Route: "a", ChatID: -100, MessageID: 41}})
+
+	tests := []struct {
+		name      string
+		chatID    int64
+		messageID int
+		postID    int
+	}{
+		{"first", -100, 40, 7},
+		{"second", -100, 41, 8},
+		{"other chat", -200, 40, 0},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			post, err := vtCli.repliedPost(&tb.Message{ID: testCase.messageID, Chat: &tb.Chat{ID: testCase.chatID}})
+
+			if testCase.postID == 0 {
+				if !errors.Is(err, errNotFound) {
+					t.Errorf("expected not found, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if post.PostID != testCase.postID {
+				t.Errorf("expected post %d, got %d", testCase.postID, post.PostID)
+			}
+
+			if url := commentURL(post, 3); url != "https://vk.com/wall-5_"+strconv.Itoa(testCase.postID)+"?reply=3" {
+				t.Errorf("unexpected comment URL %s", url)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package vk2tg
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// defaultFilter is the substring a post must contain when a route has no filter of its own.
+const defaultFilter = "#поиск"
+
+// Pipeline is the routing configuration loaded from a YAML file.
+type Pipeline struct {
+	Admins  []int64       `yaml:"admins"`
+	Sources []*VKSource   `yaml:"sources"`
+	Feeds   []*FeedSource `yaml:"feeds"`
+	Routes  []*Route      `yaml:"routes"`
+	Dedup   *Dedup        `yaml:"dedup"`
+	Media   *Media        `yaml:"media"`
+	Alerts  *Alerts       `yaml:"alerts"`
+	Sinks   []*SinkConfig `yaml:"sinks"`
+	// VKReplies enables the /comment command
+	VKReplies *VKReplies `yaml:"vkReplies"`
+	// Language of the bot UI for chats without a /language setting and users of other languages
+	Language string `yaml:"language"`
+}
+
+// Route describes which posts are delivered to a chat and how.
+type Route struct {
+	Name       string      `yaml:"name"`
+	ChatID     int64       `yaml:"chatId"`
+	Filter     string      `yaml:"filter"`
+	Sources    []string    `yaml:"sources"`
+	QuietHours *QuietHours `yaml:"quietHours"`
+	Digest     *Digest     `yaml:"digest"`
+	Comments   *Comments   `yaml:"comments"`
+	Geo        *Geo        `yaml:"geo"`
+	Moderation *Moderation `yaml:"moderation"`
+	Template   *Template   `yaml:"template"`
+	Images     *Images     `yaml:"images"`
+	Schedule   *Schedule   `yaml:"schedule"`
+	Actions    *Actions    `yaml:"actions"`
+	// Sinks are outputs besides the chat, a route with sinks and no chat ID only uses them
+	Sinks []string `yaml:"sinks"`
+
+	// Owner IDs of Sources and Sinks, resolved on load
+	owners []int
+	sinks  []*SinkConfig
+}
+
+// LoadPipeline reads and validates the routing configuration.
+func LoadPipeline(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read pipeline config")
+	}
+
+	pipeline := new(Pipeline)
+
+	err = yaml.Unmarshal(data, pipeline)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse pipeline config")
+	}
+
+	if len(pipeline.Sources) == 0 && len(pipeline.Feeds) == 0 {
+		pipeline.Sources = []*VKSource{defaultSource()}
+	}
+
+	owners := make(map[string]int, len(pipeline.Sources)+len(pipeline.Feeds))
+
+	for index, source := range pipeline.Sources {
+		if source.Name == "" || source.OwnerID == 0 {
+			return nil, errors.Newf("source #%d needs a name and an owner ID", index)
+		}
+
+		if _, ok := owners[source.Name]; ok {
+			return nil, errors.Newf("source %s: name is already taken", source.Name)
+		}
+
+		owners[source.Name] = source.OwnerID
+	}
+
+	for index, feed := range pipeline.Feeds {
+		err = feed.init()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid feed #%d", index)
+		}
+
+		if _, ok := owners[feed.Name]; ok {
+			return nil, errors.Newf("feed %s: name is already taken by another source", feed.Name)
+		}
+
+		for name, ownerID := range owners {
+			if ownerID == feed.ownerID {
+				return nil, errors.Newf("feed %s: owner ID clashes with feed %s, rename one of them", feed.Name, name)
+			}
+		}
+
+		owners[feed.Name] = feed.ownerID
+	}
+
+	if pipeline.Language != "" && supportedLanguage(pipeline.Language) != pipeline.Language {
+		return nil, errors.Newf("unsupported language %q", pipeline.Language)
+	}
+
+	if pipeline.Dedup != nil {
+		err = pipeline.Dedup.init()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid dedup")
+		}
+	}
+
+	sinks := make(map[string]*SinkConfig, len(pipeline.Sinks))
+
+	for index, sink := range pipeline.Sinks {
+		err = sink.init()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid sink #%d", index)
+		}
+
+		if _, ok := sinks[sink.Name]; ok {
+			return nil, errors.Newf("sink %s: name is already taken", sink.Name)
+		}
+
+		sinks[sink.Name] = sink
+	}
+
+	if pipeline.Alerts != nil {
+		err = pipeline.Alerts.init()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid alerts")
+		}
+	}
+
+	if pipeline.VKReplies != nil {
+		err = pipeline.VKReplies.init()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid VK replies")
+		}
+	}
+
+	if pipeline.Media != nil {
+		err = pipeline.Media.init()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid media")
+		}
+	}
+
+	// Queues, held posts, statistics and archived deliveries are keyed by route name.
+	routes := make(map[string]bool, len(pipeline.Routes))
+
+	for index, route := range pipeline.Routes {
+		if route.Name == "" {
+			return nil, errors.Newf("route #%d has no name", index)
+		}
+
+		if routes[route.Name] {
+			return nil, errors.Newf("route %s: name is already taken", route.Name)
+		}
+
+		routes[route.Name] = true
+
+		for _, name := range route.Sources {
+			ownerID, ok := owners[name]
+			if !ok {
+				return nil, errors.Newf("route %s: unknown source %q", route.Name, name)
+			}
+
+			route.owners = append(route.owners, ownerID)
+		}
+
+		for _, name := range route.Sinks {
+			sink, ok := sinks[name]
+			if !ok {
+				return nil, errors.Newf("route %s: unknown sink %q", route.Name, name)
+			}
+
+			route.sinks = append(route.sinks, sink)
+		}
+
+		if route.Digest != nil && route.ChatID == 0 && len(route.sinks) > 0 {
+			return nil, errors.Newf("route %s: digests are only sent to the route chat", route.Name)
+		}
+
+		if route.QuietHours != nil {
+			err = route.QuietHours.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid quiet hours", route.Name)
+			}
+		}
+
+		if route.Digest != nil {
+			err = route.Digest.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid digest", route.Name)
+			}
+		}
+
+		if route.Comments != nil {
+			err = route.Comments.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid comments", route.Name)
+			}
+		}
+
+		if route.Geo != nil {
+			err = route.Geo.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid geo", route.Name)
+			}
+		}
+
+		if route.Moderation != nil {
+			err = route.Moderation.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid moderation", route.Name)
+			}
+		}
+
+		if route.Template != nil {
+			err = route.Template.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid template", route.Name)
+			}
+		}
+
+		if route.Images != nil {
+			err = route.Images.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid images", route.Name)
+			}
+		}
+
+		if route.Schedule != nil {
+			err = route.Schedule.init()
+			if err != nil {
+				return nil, errors.Wrapf(err, "route %s: invalid schedule", route.Name)
+			}
+		}
+	}
+
+	return pipeline, nil
+}
+
+// WithPipeline replaces the default source and route with the configured ones.
+func (vtCli *VTClinent) WithPipeline(pipeline *Pipeline) *VTClinent {
+	vtCli.sources = make([]Source, 0, len(pipeline.Sources)+len(pipeline.Feeds))
+
+	for _, source := range pipeline.Sources {
+		vtCli.sources = append(vtCli.sources, source)
+	}
+
+	for _, feed := range pipeline.Feeds {
+		vtCli.sources = append(vtCli.sources, feed)
+	}
+
+	vtCli.routes = pipeline.Routes
+	vtCli.dedup = pipeline.Dedup
+	vtCli.media = pipeline.Media
+	vtCli.vkReplies = pipeline.VKReplies
+
+	if pipeline.Language != "" {
+		vtCli.language = pipeline.Language
+	}
+
+	for _, sink := range pipeline.Sinks {
+		if sink.Telegram != nil {
+			sink.Telegram.vtCli = vtCli
+		}
+	}
+
+	if pipeline.Alerts != nil {
+		vtCli.alerts = pipeline.Alerts
+	}
+	vtCli.admins = pipeline.Admins
+
+	return vtCli
+}
+
+// defaultRoute reproduces the original behaviour: every "#поиск" post goes to the configured user.
+func (vtCli *VTClinent) defaultRoute() *Route {
+	return &Route{
+		Name:   "default",
+		ChatID: vtCli.config.TGUser,
+		Filter: defaultFilter,
+	}
+}
+
+// route returns the configured route by name.
+func (vtCli *VTClinent) route(name string) *Route {
+	for _, route := range vtCli.routes {
+		if route.Name == name {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// Match reports whether the post should be delivered by the route.
+func (route *Route) Match(post *vkObject.WallWallpost) bool {
+	return route.mismatch(post) == ""
+}
+
+// mismatch explains why the route skips the post, it is empty for matching posts.
+func (route *Route) mismatch(post *vkObject.WallWallpost) string {
+	if len(route.owners) > 0 && !slices.Contains(route.owners, post.OwnerID) {
+		return "source is not routed"
+	}
+
+	if route.Geo != nil && !route.Geo.match(post) {
+		return "outside of the geo area"
+	}
+
+	if route.Filter != "" && !strings.Contains(post.Text, route.Filter) {
+		return "no " + strconv.Quote(route.Filter) + " in text"
+	}
+
+	return ""
+}
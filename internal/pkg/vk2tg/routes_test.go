@@ -0,0 +1,58 @@
+package vk2tg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadPipelineNames tests that sources, sinks and routes need unique names.
+func TestLoadPipelineNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "unique",
+			config: "routes:\n  - name: search\n    chatId: 1\n  - name: all\n    chatId: 2\n",
+		},
+		{
+			name:   "route",
+			config: "routes:\n  - name: search\n    chatId: 1\n  - name: search\n    chatId: 2\n",
+			err:    "route search: name is already taken",
+		},
+		{
+			name:   "source",
+			config: "sources:\n  - name: poisk\n    ownerId: -1\n  - name: poisk\n    ownerId: -2\n",
+			err:    "source poisk: name is already taken",
+		},
+		{
+			name: "sink",
+			config: "sinks:\n  - name: partners\n    webhook:\n      url: https://example.com/a\n" +
+				"  - name: partners\n    webhook:\n      url: https://example.com/b\n",
+			err: "sink partners: name is already taken",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+
+			err := os.WriteFile(path, []byte(testCase.config), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = LoadPipeline(path)
+			if testCase.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if testCase.err != "" && (err == nil || !strings.Contains(err.Error(), testCase.err)) {
+				t.Fatalf("expected error %q, got %v", testCase.err, err)
+			}
+		})
+	}
+}
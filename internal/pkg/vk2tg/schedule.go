@@ -0,0 +1,179 @@
+package vk2tg
+
+import (
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+)
+
+// Schedule spaces out the messages of a route instead of sending them as they come.
+// It is checked by the scheduler, so intervals are kept with a minute precision.
+type Schedule struct {
+	// MinInterval is the least time between two messages of the route.
+	MinInterval time.Duration `yaml:"minInterval"`
+	// Delay holds a post back for a time after it was published on VK.
+	Delay time.Duration `yaml:"delay"`
+	// Windows are daily publishing windows, posts wait outside of them.
+	Windows  []PublishWindow `yaml:"windows"`
+	Timezone string          `yaml:"timezone"`
+
+	location *time.Location
+}
+
+// PublishWindow is a daily window, it may span midnight.
+type PublishWindow struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+
+	startMinute int
+	endMinute   int
+}
+
+func (schedule *Schedule) init() error {
+	if schedule.MinInterval < 0 || schedule.Delay < 0 {
+		return errors.New("interval and delay can't be negative")
+	}
+
+	schedule.location = zone
+
+	if schedule.Timezone != "" {
+		var err error
+
+		schedule.location, err = time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return errors.Wrap(err, "unknown timezone")
+		}
+	}
+
+	for index := range schedule.Windows {
+		window := &schedule.Windows[index]
+
+		var err error
+
+		window.startMinute, err = parseClock(window.Start)
+		if err != nil {
+			return errors.Wrapf(err, "window #%d: invalid start", index)
+		}
+
+		window.endMinute, err = parseClock(window.End)
+		if err != nil {
+			return errors.Wrapf(err, "window #%d: invalid end", index)
+		}
+	}
+
+	return nil
+}
+
+// open reports whether now falls into a publishing window, always true without windows.
+func (schedule *Schedule) open(now time.Time) bool {
+	if len(schedule.Windows) == 0 {
+		return true
+	}
+
+	local := now.In(schedule.location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, schedule.location)
+
+	for index := range schedule.Windows {
+		window := &schedule.Windows[index]
+
+		if window.startMinute == window.endMinute {
+			return true
+		}
+
+		// A window that started yesterday may still be running after midnight.
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+			start := day.Add(time.Duration(window.startMinute) * time.Minute)
+
+			end := day.Add(time.Duration(window.endMinute) * time.Minute)
+			if window.endMinute < window.startMinute {
+				end = end.AddDate(0, 0, 1)
+			}
+
+			if !local.Before(start) && local.Before(end) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func queueKey(route *Route) string {
+	return "queue:" + route.Name
+}
+
+// enqueue adds the post to the route queue and returns when it is due at the earliest.
+func (vtCli *VTClinent) enqueue(route *Route, post *vkObject.WallWallpost) (time.Time, error) {
+	due := time.Unix(int64(post.Date), 0).Add(route.Schedule.Delay)
+	if due.Before(time.Now()) {
+		due = time.Now()
+	}
+
+	return due, vtCli.pushPost(queueKey(route), queuedPost{Post: post, Due: due})
+}
+
+// releaseQueued sends queued posts that are due, as far as the route schedules allow.
+// A post that fails stays queued and is tried again on a later run.
+func (vtCli *VTClinent) releaseQueued() {
+	for _, route := range vtCli.routes {
+		if route.Schedule == nil || !route.Schedule.open(time.Now()) {
+			continue
+		}
+
+		silent := vtCli.config.Silent
+
+		if route.QuietHours != nil {
+			if _, quiet := route.QuietHours.ActiveUntil(time.Now()); quiet {
+				if route.QuietHours.Mode == QuietModeHold {
+					continue
+				}
+
+				silent = true
+			}
+		}
+
+		for {
+			item := vtCli.dequeue(route)
+			if item == nil {
+				break
+			}
+
+			deliveries, err := vtCli.send(route, item.Post, silent, item.Delivered)
+			vtCli.finishPost(route, queueKey(route), item, deliveries, err)
+
+			if err != nil || route.Schedule.MinInterval > 0 {
+				break
+			}
+		}
+	}
+}
+
+// dequeue takes the first due post of the route queue if the interval since the last message allows.
+// The post stays queued, marked in flight, until finishPost removes it after sending.
+func (vtCli *VTClinent) dequeue(route *Route) *queuedPost {
+	item := vtCli.takePost(route, queueKey(route), func(queue *postQueue, item *queuedPost) bool {
+		return time.Since(queue.LastSent) >= route.Schedule.MinInterval && !time.Now().Before(item.Due)
+	})
+	if item != nil {
+		vtCli.logger.Printf("Post %d: Released from queue of route %s", item.Post.ID, route.Name)
+	}
+
+	return item
+}
+
+// queueStatus describes the route queue for /status.
+func (vtCli *VTClinent) queueStatus(route *Route, lang string) string {
+	queue, err := vtCli.loadPostQueue(queueKey(route))
+	if err != nil {
+		return translate(lang, "queue.unknown", err)
+	}
+
+	status := translate(lang, "queue.posts", len(queue.Items))
+
+	if !queue.LastSent.IsZero() {
+		status += translate(lang, "queue.lastSent", queue.LastSent.In(zone).Format(time.RFC822))
+	}
+
+	return status
+}
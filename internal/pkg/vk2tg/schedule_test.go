@@ -0,0 +1,259 @@
+package vk2tg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestScheduleOpen tests publishing windows, including one spanning midnight.
+func TestScheduleOpen(t *testing.T) {
+	schedule := &Schedule{
+		Timezone: "UTC",
+		Windows:  []PublishWindow{{Start: "09:00", End: "12:00"}, {Start: "22:00", End: "02:00"}},
+	}
+
+	err := schedule.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		clock    string
+		expected bool
+	}{
+		{"morning", "10:30", true},
+		{"window end", "12:00", false},
+		{"afternoon", "15:00", false},
+		{"late evening", "23:30", true},
+		{"after midnight", "01:59", true},
+		{"night", "03:00", false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			now, err := time.Parse("2006-01-02 15:04", "2024-03-05 "+testCase.clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if open := schedule.open(now); open != testCase.expected {
+				t.Errorf("expected open %t at %s, got %t", testCase.expected, testCase.clock, open)
+			}
+		})
+	}
+}
+
+// TestQueue tests that queued posts are released one per interval and survive a restart.
+func TestQueue(t *testing.T) {
+	shared := newMemoryStorage()
+	route := &Route{Name: "spaced", Schedule: &Schedule{MinInterval: time.Hour, Delay: time.Minute}}
+
+	err := route.Schedule.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newReplica := func() *VTClinent {
+		vtCli := NewVTClient("", "", 0, 1)
+		vtCli.storage = shared
+		vtCli.routes = []*Route{route}
+		vtCli.webhook = &tb.Webhook{}
+		vtCli.campaign()
+
+		return vtCli
+	}
+
+	vtCli := newReplica()
+
+	old := int(time.Now().Add(-time.Hour).Unix())
+	fresh := int(time.Now().Unix())
+
+	for _, post := range []*vkObject.WallWallpost{{ID: 1, Date: old}, {ID: 2, Date: fresh}, {ID: 3, Date: old}} {
+		_, err = vtCli.enqueue(route, post)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Post 2 waits for its delay, so the next due post goes first.
+	item := vtCli.dequeue(route)
+	if item == nil || item.Post.ID != 1 {
+		t.Fatalf("expected post 1, got %+v", item)
+	}
+
+	vtCli.finishPost(route, queueKey(route), item, nil, nil)
+
+	if item := vtCli.dequeue(route); item != nil {
+		t.Fatalf("expected the interval to hold post %d back", item.Post.ID)
+	}
+
+	// A restarted replica finds the rest of the queue.
+	vtCli.stepDown("restart")
+	shared.Delete(leaderKey) //nolint:errcheck // memory storage
+
+	restarted := newReplica()
+
+	queue, err := restarted.loadPostQueue(queueKey(route))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Items) != 2 || queue.Items[0].Post.ID != 2 || queue.Items[1].Post.ID != 3 {
+		t.Fatalf("unexpected queue after restart: %+v", queue.Items)
+	}
+
+	queue.LastSent = time.Now().Add(-2 * time.Hour)
+
+	err = restarted.savePostQueue(queueKey(route), queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item := restarted.dequeue(route); item == nil || item.Post.ID != 3 {
+		t.Fatalf("expected post 3, got %+v", item)
+	}
+}
+
+// TestQueueCrash tests that a post taken off the queue by a leader that crashed before
+// finishing is sent again unless the archive shows it was delivered.
+func TestQueueCrash(t *testing.T) {
+	tests := []struct {
+		name     string
+		archived []sentMessage
+		resent   bool
+	}{
+		{"crash before send", nil, true},
+		{"crash after delivery", []sentMessage{{Route: "spaced", ChatID: 42, MessageID: 7}}, false},
+		{"crash after sink delivery", []sentMessage{{Route: "spaced", Sink: "hook"}}, false},
+		{"crash after delivery via another route", []sentMessage{{Route: "instant", ChatID: 42, MessageID: 7}}, true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			shared := newMemoryStorage()
+			route := &Route{Name: "spaced", Schedule: &Schedule{MinInterval: time.Hour}}
+
+			err := route.Schedule.init()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newReplica := func() *VTClinent {
+				vtCli := NewVTClient("", "", 0, 1)
+				vtCli.storage = shared
+				vtCli.routes = []*Route{route}
+				vtCli.webhook = &tb.Webhook{}
+				vtCli.campaign()
+
+				return vtCli
+			}
+
+			crashed := newReplica()
+
+			_, err = crashed.enqueue(route, &vkObject.WallWallpost{ID: 1, OwnerID: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			item := crashed.dequeue(route)
+			if item == nil {
+				t.Fatal("expected a post to send")
+			}
+
+			if testCase.archived != nil {
+				crashed.archivePost(item.Post, testCase.archived)
+			}
+
+			// The leader dies without finishing, its lease expires.
+			crashed.stepDown("crash")
+			shared.Delete(leaderKey) //nolint:errcheck // memory storage
+
+			restarted := newReplica()
+
+			item = restarted.dequeue(route)
+			if resent := item != nil; resent != testCase.resent {
+				t.Fatalf("expected resent %t, got post %+v", testCase.resent, item)
+			}
+
+			if item != nil {
+				restarted.finishPost(route, queueKey(route), item, nil, nil)
+			}
+
+			queue, err := restarted.loadPostQueue(queueKey(route))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(queue.Items) != 0 || queue.InFlight != "" {
+				t.Errorf("expected an empty queue, got %+v", queue)
+			}
+		})
+	}
+}
+
+// TestQueueFailure tests that a post that failed to send stays queued and is archived once delivered,
+// even by a route with sinks only.
+func TestQueueFailure(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			writer.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	sink := &SinkConfig{Name: "hook", Retry: Retry{Attempts: 1}, Webhook: &WebhookSink{URL: server.URL}}
+
+	err := sink.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route := &Route{Name: "spaced", Schedule: &Schedule{MinInterval: time.Hour}, sinks: []*SinkConfig{sink}}
+
+	err = route.Schedule.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.routes = []*Route{route}
+	vtCli.webhook = &tb.Webhook{}
+	vtCli.campaign()
+
+	_, err = vtCli.enqueue(route, &vkObject.WallWallpost{ID: 1, OwnerID: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vtCli.releaseQueued()
+
+	queue, err := vtCli.loadPostQueue(queueKey(route))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Items) != 1 || queue.Items[0].Attempts != 1 || queue.InFlight != "" || !queue.LastSent.IsZero() {
+		t.Fatalf("expected the post queued for another attempt, got %+v", queue)
+	}
+
+	vtCli.releaseQueued()
+
+	queue, err = vtCli.loadPostQueue(queueKey(route))
+	if err != nil || len(queue.Items) != 0 {
+		t.Fatalf("expected an empty queue, got %+v, %v", queue, err)
+	}
+
+	posts, err := vtCli.archivedPosts(0)
+	if err != nil || len(posts) != 1 || !delivered(posts[0].Deliveries, "spaced", "hook") {
+		t.Errorf("expected the sink delivery archived, got %+v, %v", posts, err)
+	}
+}
@@ -0,0 +1,339 @@
+package vk2tg
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// Actions a matching route takes on a post in a dry run.
+const (
+	actionSend     = "send"
+	actionModerate = "moderate"
+	actionDigest   = "digest"
+	actionHold     = "hold"
+	actionQueue    = "queue"
+)
+
+// simulation is the dry run report of a single post.
+type simulation struct {
+	Post   int             `json:"post"`
+	Owner  int             `json:"owner"`
+	Source string          `json:"source"`
+	URL    string          `json:"url"`
+	Routes []routeDecision `json:"routes"`
+	// Duplicate is the forwarded post this one would be dropped as a near-duplicate of
+	Duplicate *duplicateOf `json:"duplicate,omitempty"`
+}
+
+// duplicateOf references the original of a near-duplicate and what happens to the copy.
+type duplicateOf struct {
+	Post   int    `json:"post"`
+	Owner  int    `json:"owner"`
+	Source string `json:"source"`
+	Mode   string `json:"mode"`
+}
+
+// routeDecision is what a route would do with the post.
+type routeDecision struct {
+	Route  string    `json:"route"`
+	Match  bool      `json:"match"`
+	Reason string    `json:"reason,omitempty"`
+	Action string    `json:"action,omitempty"`
+	Silent bool      `json:"silent,omitempty"`
+	Sinks  []string  `json:"sinks,omitempty"`
+	Calls  []botCall `json:"calls,omitempty"`
+}
+
+// botCall is a Bot API request that would have been made.
+type botCall struct {
+	Method string         `json:"method"`
+	Params map[string]any `json:"params"`
+}
+
+// simulatedButton is an inline button, Callback names the handler of callback buttons.
+type simulatedButton struct {
+	Text     string `json:"text"`
+	URL      string `json:"url,omitempty"`
+	Callback string `json:"callback,omitempty"`
+}
+
+// WithDryRun makes the client print filter decisions and rendered messages to out instead of calling the Bot API.
+func (vtCli *VTClinent) WithDryRun(out io.Writer) *VTClinent {
+	vtCli.dryRun = out
+
+	return vtCli
+}
+
+// Simulate pushes recorded wall posts through filters, routes and templates as a dry run.
+// The fixtures file holds a JSON array of posts or a wall.get response.
+func (vtCli *VTClinent) Simulate(fixtures string) error {
+	data, err := os.ReadFile(fixtures)
+	if err != nil {
+		return errors.Wrap(err, "can't read fixtures")
+	}
+
+	posts, err := parseFixtures(data)
+	if err != nil {
+		return err
+	}
+
+	if vtCli.dryRun == nil {
+		vtCli.dryRun = os.Stdout
+	}
+
+	if vtCli.config.VKToken != "" {
+		vtCli.vkClient = vkapi.NewVK(vtCli.config.VKToken)
+	}
+
+	vtCli.initRoutes()
+
+	for index := range posts {
+		vtCli.process(&posts[index])
+	}
+
+	return nil
+}
+
+// parseFixtures reads posts, oldest first, from a JSON array of posts,
+// a wall.get response or a raw API reply wrapping one.
+func parseFixtures(data []byte) ([]vkObject.WallWallpost, error) {
+	var posts []vkObject.WallWallpost
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err := json.Unmarshal(data, &posts)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't decode fixtures")
+		}
+	} else {
+		var reply struct {
+			vkapi.WallGetResponse
+
+			Response *vkapi.WallGetResponse `json:"response"`
+		}
+
+		err := json.Unmarshal(data, &reply)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't decode fixtures")
+		}
+
+		posts = reply.Items
+		if reply.Response != nil {
+			posts = reply.Response.Items
+		}
+	}
+
+	if len(posts) == 0 {
+		return nil, errors.New("no posts in fixtures")
+	}
+
+	slices.SortStableFunc(posts, func(first, second vkObject.WallWallpost) int { return first.Date - second.Date })
+
+	return posts, nil
+}
+
+// simulate prints what every route would do with the post.
+func (vtCli *VTClinent) simulate(post *vkObject.WallWallpost) {
+	report := simulation{
+		Post:   post.ID,
+		Owner:  post.OwnerID,
+		Source: vtCli.sourceName(post.OwnerID),
+		URL:    postURL(post),
+	}
+
+	if vtCli.dedup != nil {
+		if original := vtCli.simulateDuplicate(vtCli.fingerprint(post)); original != nil {
+			report.Duplicate = &duplicateOf{
+				Post:   original.PostID,
+				Owner:  original.OwnerID,
+				Source: original.Source,
+				Mode:   vtCli.dedup.Mode,
+			}
+		}
+	}
+
+	// duplicates are dropped before routing
+	if report.Duplicate == nil {
+		for _, route := range vtCli.routes {
+			report.Routes = append(report.Routes, vtCli.decide(route, post))
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't encode dry run report: %s", post.ID, err)
+
+		return
+	}
+
+	_, err = vtCli.dryRun.Write(append(data, '\n'))
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't write dry run report: %s", post.ID, err)
+	}
+}
+
+// decide mirrors dispatch and publish without side effects.
+func (vtCli *VTClinent) decide(route *Route, post *vkObject.WallWallpost) routeDecision {
+	decision := routeDecision{Route: route.Name, Reason: route.mismatch(post)}
+	if decision.Reason != "" {
+		return decision
+	}
+
+	decision.Match = true
+
+	switch {
+	case route.Moderation != nil:
+		decision.Action = actionModerate
+
+		pending := &pendingPost{Route: route.Name, Post: post}
+		lang := vtCli.chatLanguage(route.Moderation.ChatID)
+
+		decision.Calls = append(simulatePhotos(route.Moderation.ChatID, post, false), botCall{
+			Method: "sendMessage",
+			Params: map[string]any{
+				"chat_id":      route.Moderation.ChatID,
+				"text":         vtCli.previewText(pending, "", lang),
+				"reply_markup": simulateKeyboard(moderationMarkup(pending, lang)),
+			},
+		})
+
+		return decision
+	case route.Digest != nil:
+		decision.Action = actionDigest
+
+		return decision
+	}
+
+	decision.Action = actionSend
+	decision.Silent = vtCli.config.Silent
+
+	if route.QuietHours != nil {
+		if until, quiet := route.QuietHours.ActiveUntil(time.Now()); quiet {
+			if route.QuietHours.Mode == QuietModeHold {
+				decision.Action = actionHold
+				decision.Reason = "quiet hours until " + until.Format(time.RFC822)
+
+				return decision
+			}
+
+			decision.Silent = true
+		}
+	}
+
+	// Queued posts are sent later the same way.
+	if route.Schedule != nil {
+		decision.Action = actionQueue
+	}
+
+	for _, sink := range route.sinks {
+		decision.Sinks = append(decision.Sinks, sink.Name)
+	}
+
+	if route.ChatID != 0 {
+		decision.Calls = vtCli.simulateDelivery(route, post, decision.Silent)
+	}
+
+	return decision
+}
+
+// simulateDelivery returns the calls deliverTo would make.
+func (vtCli *VTClinent) simulateDelivery(route *Route, post *vkObject.WallWallpost, silent bool) []botCall {
+	calls := simulatePhotos(route.ChatID, post, silent)
+
+	text, options, err := vtCli.renderPost(route, route.ChatID, post, silent)
+	if err != nil {
+		return append(calls, botCall{Method: "sendMessage", Params: map[string]any{"error": err.Error()}})
+	}
+
+	params := map[string]any{
+		"chat_id": route.ChatID,
+		"text":    text,
+	}
+
+	if options.ParseMode != tb.ModeDefault {
+		params["parse_mode"] = options.ParseMode
+	}
+
+	if silent {
+		params["disable_notification"] = true
+	}
+
+	if keyboard := simulateKeyboard(options.ReplyMarkup); len(keyboard) > 0 {
+		params["reply_markup"] = keyboard
+	}
+
+	calls = append(calls, botCall{Method: "sendMessage", Params: params})
+
+	if route.Geo != nil && route.Geo.Send {
+		if point := postGeo(post); point != nil {
+			params := map[string]any{
+				"chat_id":   route.ChatID,
+				"latitude":  point.Latitude,
+				"longitude": point.Longitude,
+			}
+
+			method := "sendLocation"
+			if point.Title != "" {
+				method = "sendVenue"
+				params["title"] = point.Title
+			}
+
+			calls = append(calls, botCall{Method: method, Params: params})
+		}
+	}
+
+	return calls
+}
+
+// simulatePhotos returns the album sendPhotos would send, by the original URLs.
+func simulatePhotos(chatID int64, post *vkObject.WallWallpost, silent bool) []botCall {
+	var media []string
+
+	for index := range post.Attachments {
+		if post.Attachments[index].Type != "photo" {
+			continue
+		}
+
+		if url := photoURL(&post.Attachments[index].Photo); url != "" {
+			media = append(media, url)
+		}
+	}
+
+	if len(media) == 0 {
+		return nil
+	}
+
+	return []botCall{{Method: "sendMediaGroup", Params: map[string]any{
+		"chat_id":              chatID,
+		"media":                media,
+		"disable_notification": silent,
+	}}}
+}
+
+func simulateKeyboard(markup *tb.ReplyMarkup) [][]simulatedButton {
+	if markup == nil {
+		return nil
+	}
+
+	keyboard := make([][]simulatedButton, 0, len(markup.InlineKeyboard))
+
+	for _, row := range markup.InlineKeyboard {
+		buttons := make([]simulatedButton, 0, len(row))
+
+		for _, button := range row {
+			buttons = append(buttons, simulatedButton{Text: button.Text, URL: button.URL, Callback: button.Unique})
+		}
+
+		keyboard = append(keyboard, buttons)
+	}
+
+	return keyboard
+}
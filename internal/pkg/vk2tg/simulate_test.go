@@ -0,0 +1,163 @@
+package vk2tg
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// TestParseFixtures tests the accepted shapes of recorded posts.
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		ids  []int
+	}{
+		{"array", `[{"id": 2, "date": 20}, {"id": 1, "date": 10}]`, []int{1, 2}},
+		{"wall.get", `{"count": 1, "items": [{"id": 3}]}`, []int{3}},
+		{"api reply", `{"response": {"count": 2, "items": [{"id": 5, "date": 50}, {"id": 4, "date": 40}]}}`, []int{4, 5}},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			posts, err := parseFixtures([]byte(testCase.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(posts) != len(testCase.ids) {
+				t.Fatalf("expected %d posts, got %d", len(testCase.ids), len(posts))
+			}
+
+			for index, id := range testCase.ids {
+				if posts[index].ID != id {
+					t.Errorf("post %d: expected ID %d, got %d", index, id, posts[index].ID)
+				}
+			}
+		})
+	}
+
+	_, err := parseFixtures([]byte(`{"items": []}`))
+	if err == nil {
+		t.Error("expected an error for empty fixtures")
+	}
+}
+
+// TestSimulate tests that a dry run reports decisions and rendered messages without a bot.
+func TestSimulate(t *testing.T) {
+	fixtures := filepath.Join(t.TempDir(), "wall.json")
+
+	err := os.WriteFile(fixtures, []byte(`[{"id": 1, "owner_id": -1, "text": "Lost cat #поиск"}, {"id": 2, "owner_id": -1, "text": "News"}]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	vtCli := NewVTClient("", "", 42, 1).WithDryRun(&out)
+	vtCli.sources = []Source{&VKSource{Name: "poisk", OwnerID: -1}}
+
+	err = vtCli.Simulate(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(&out)
+
+	var reports []simulation
+
+	for decoder.More() {
+		var report simulation
+
+		err = decoder.Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+
+	sent := reports[0].Routes[0]
+	if !sent.Match || sent.Action != actionSend || len(sent.Calls) != 1 || sent.Calls[0].Params["text"] != "Lost cat #поиск" {
+		t.Errorf("unexpected decision for a matching post: %+v", sent)
+	}
+
+	skipped := reports[1].Routes[0]
+	if skipped.Match || skipped.Reason == "" || len(skipped.Calls) != 0 {
+		t.Errorf("unexpected decision for a filtered post: %+v", skipped)
+	}
+}
+
+// TestSimulateDuplicate tests that dry runs report near-duplicates without storing fingerprints.
+func TestSimulateDuplicate(t *testing.T) {
+	fixtures := filepath.Join(t.TempDir(), "wall.json")
+
+	err := os.WriteFile(fixtures, []byte(`[`+
+		`{"id": 1, "owner_id": -1, "date": 10, "text": "Lost a grey cat near the central park yesterday #поиск"},`+
+		`{"id": 7, "owner_id": -2, "date": 20, "text": "Lost a grey cat near the central park yesterday #поиск"},`+
+		`{"id": 2, "owner_id": -1, "date": 30, "text": "Found a black dog on the river bank #поиск"}]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	vtCli := NewVTClient("", "", 42, 1).WithDryRun(&out)
+	vtCli.sources = []Source{&VKSource{Name: "poisk", OwnerID: -1}, &VKSource{Name: "lapki", OwnerID: -2}}
+	vtCli.dedup = &Dedup{Window: time.Hour}
+
+	err = vtCli.dedup.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vtCli.Simulate(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := json.NewDecoder(&out)
+
+	var reports []simulation
+
+	for decoder.More() {
+		var report simulation
+
+		err = decoder.Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 reports, got %d", len(reports))
+	}
+
+	for index, report := range reports {
+		if (report.Duplicate != nil) != (index == 1) {
+			t.Errorf("post %d: unexpected duplicate %+v", report.Post, report.Duplicate)
+		}
+	}
+
+	duplicate := reports[1]
+	if *duplicate.Duplicate != (duplicateOf{Post: 1, Owner: -1, Source: "poisk", Mode: DedupModeSuppress}) ||
+		len(duplicate.Routes) != 0 {
+		t.Errorf("unexpected report of a duplicate: %+v", duplicate)
+	}
+
+	_, err = vtCli.storage.Get(fingerprintsKey)
+	if !errors.Is(err, errNotFound) {
+		t.Errorf("expected no stored fingerprints, got %v", err)
+	}
+}
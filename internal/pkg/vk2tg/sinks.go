@@ -0,0 +1,433 @@
+package vk2tg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 2 * time.Second
+	defaultSinkTimeout   = 30 * time.Second
+	// sinkErrorBodyLimit is how much of an error response is kept for logs.
+	sinkErrorBodyLimit = 512
+)
+
+// errPermanent marks sink errors that won't go away on retry, like a rejected request.
+var errPermanent = errors.New("permanent failure")
+
+// errMaybeDelivered marks failures after the request reached the server, like a timeout
+// waiting for the response. Only idempotent sinks retry them, others would deliver twice.
+var errMaybeDelivered = errors.New("may have been delivered")
+
+// Sink is an output for forwarded posts besides the route chat.
+type Sink interface {
+	Send(ctx context.Context, message *outgoing) error
+}
+
+// idempotentSink is a sink whose server drops repeated deliveries of a post.
+type idempotentSink interface {
+	Sink
+	idempotent()
+}
+
+// SinkConfig is a named output routes refer to. Exactly one of the outputs has to be set.
+type SinkConfig struct {
+	Name     string        `yaml:"name"`
+	Retry    Retry         `yaml:"retry"`
+	Template *Template     `yaml:"template"`
+	Telegram *TelegramSink `yaml:"telegram"`
+	Webhook  *WebhookSink  `yaml:"webhook"`
+	Discord  *DiscordSink  `yaml:"discord"`
+	Matrix   *MatrixSink   `yaml:"matrix"`
+	Email    *EmailSink    `yaml:"email"`
+
+	sink Sink
+}
+
+// Retry is the retry policy of a sink: the pause after a failed attempt doubles with every retry.
+type Retry struct {
+	Attempts int           `yaml:"attempts"`
+	Backoff  time.Duration `yaml:"backoff"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// outgoing is a post on its way to sinks.
+type outgoing struct {
+	route  *Route
+	post   *vkObject.WallWallpost
+	view   *postView
+	text   string
+	photos []string
+	silent bool
+	// template is the sink template the text was rendered with, nil for the post text
+	template *Template
+}
+
+func (config *SinkConfig) init() error {
+	if config.Name == "" {
+		return errors.New("name is required")
+	}
+
+	var sinks []Sink
+
+	if config.Telegram != nil {
+		sinks = append(sinks, config.Telegram)
+	}
+
+	if config.Webhook != nil {
+		sinks = append(sinks, config.Webhook)
+	}
+
+	if config.Discord != nil {
+		sinks = append(sinks, config.Discord)
+	}
+
+	if config.Matrix != nil {
+		sinks = append(sinks, config.Matrix)
+	}
+
+	if config.Email != nil {
+		sinks = append(sinks, config.Email)
+	}
+
+	if len(sinks) != 1 {
+		return errors.New("exactly one of telegram, webhook, discord, matrix and email is required")
+	}
+
+	config.sink = sinks[0]
+
+	if initer, ok := config.sink.(interface{ init() error }); ok {
+		err := initer.init()
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Template != nil {
+		err := config.Template.init()
+		if err != nil {
+			return errors.Wrap(err, "invalid template")
+		}
+	}
+
+	return config.Retry.init()
+}
+
+func (retry *Retry) init() error {
+	if retry.Attempts == 0 {
+		retry.Attempts = defaultRetryAttempts
+	}
+
+	if retry.Backoff == 0 {
+		retry.Backoff = defaultRetryBackoff
+	}
+
+	if retry.Timeout == 0 {
+		retry.Timeout = defaultSinkTimeout
+	}
+
+	if retry.Attempts < 0 || retry.Backoff < 0 || retry.Timeout < 0 {
+		return errors.New("retry settings must be positive")
+	}
+
+	return nil
+}
+
+// send delivers the message, retrying failures that may be temporary.
+func (config *SinkConfig) send(message *outgoing) error {
+	backoff := config.Retry.Backoff
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Retry.Timeout)
+		err = config.sink.Send(ctx, message)
+
+		cancel()
+
+		if err == nil || errors.Is(err, errPermanent) || attempt >= config.Retry.Attempts {
+			return err
+		}
+
+		if _, ok := config.sink.(idempotentSink); !ok && errors.Is(err, errMaybeDelivered) {
+			return err
+		}
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+	}
+}
+
+// newOutgoing renders the post once for every sink of the route.
+func (vtCli *VTClinent) newOutgoing(route *Route, post *vkObject.WallWallpost, silent bool) *outgoing {
+	message := &outgoing{
+		route:  route,
+		post:   post,
+		view:   vtCli.newPostView(route, post),
+		text:   post.Text,
+		silent: silent,
+	}
+
+	for index := range post.Attachments {
+		if post.Attachments[index].Type != "photo" {
+			continue
+		}
+
+		if url := photoURL(&post.Attachments[index].Photo); url != "" {
+			message.photos = append(message.photos, url)
+		}
+	}
+
+	return message
+}
+
+// forSink applies the sink template to the message text.
+func (message *outgoing) forSink(config *SinkConfig) (*outgoing, error) {
+	if config.Template == nil {
+		return message, nil
+	}
+
+	text, _, err := config.Template.render(message.view)
+	if err != nil {
+		return nil, err
+	}
+
+	custom := *message
+	custom.text = text
+	custom.template = config.Template
+
+	return &custom, nil
+}
+
+// sendToSinks hands the post to every sink of the route at once and waits for them
+// to know where it was delivered. Sinks in done are skipped. The error only reports
+// failures worth another attempt: rejected posts and possible deliveries are not.
+func (vtCli *VTClinent) sendToSinks(
+	route *Route, post *vkObject.WallWallpost, silent bool, done []sentMessage,
+) ([]sentMessage, error) {
+	if len(route.sinks) == 0 {
+		return nil, nil
+	}
+
+	message := vtCli.newOutgoing(route, post, silent)
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		deliveries []sentMessage
+		failed     error
+	)
+
+	for _, config := range route.sinks {
+		if delivered(done, route.Name, config.Name) {
+			continue
+		}
+
+		wg.Go(func() {
+			custom, err := message.forSink(config)
+			if err == nil {
+				err = config.send(custom)
+			}
+
+			vtCli.countDelivery(route, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				vtCli.logger.Printf("Post %d: Sent to sink %s of route %s", post.ID, config.Name, route.Name)
+			case errors.Is(err, errMaybeDelivered):
+				// Sending the post again could deliver it twice, so it counts as delivered.
+				vtCli.logger.Printf("Post %d: Sink %s of route %s may have got it: %s", post.ID, config.Name, route.Name, err)
+			case errors.Is(err, errPermanent):
+				vtCli.logger.Printf("Post %d: Sink %s of route %s rejected it: %s", post.ID, config.Name, route.Name, err)
+
+				return
+			default:
+				vtCli.logger.Printf("Post %d: Can't send to sink %s of route %s: %s", post.ID, config.Name, route.Name, err)
+
+				failed = errors.CombineErrors(failed, errors.Wrapf(err, "sink %s", config.Name))
+
+				return
+			}
+
+			deliveries = append(deliveries, sentMessage{Route: route.Name, Sink: config.Name})
+		})
+	}
+
+	wg.Wait()
+
+	return deliveries, failed
+}
+
+// TelegramSink sends posts to another Telegram chat, rendered with the sink template or else the route one.
+type TelegramSink struct {
+	ChatID int64 `yaml:"chatId"`
+
+	vtCli *VTClinent
+}
+
+func (sink *TelegramSink) init() error {
+	if sink.ChatID == 0 {
+		return errors.New("telegram: chat ID is required")
+	}
+
+	return nil
+}
+
+func (sink *TelegramSink) Send(_ context.Context, message *outgoing) error {
+	if sink.vtCli == nil {
+		return errors.Mark(errors.New("telegram: bot is not set up"), errPermanent)
+	}
+
+	tmpl := message.route.Template
+	if message.template != nil {
+		tmpl = message.template
+	}
+
+	_, err := sink.vtCli.deliverWith(message.route, tmpl, sink.ChatID, message.post, message.silent)
+
+	return classifyTelegram(err)
+}
+
+// classifyTelegram marks a failed Bot API call the way sendJSON marks a failed request:
+// rejected calls are permanent failures and lost or failed ones may have been delivered.
+func classifyTelegram(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if requestSent(err) {
+			return errors.Mark(err, errMaybeDelivered)
+		}
+
+		return err
+	}
+
+	code := telegramCode(err)
+
+	switch {
+	case code == http.StatusTooManyRequests:
+		return err
+	case code >= http.StatusInternalServerError:
+		return errors.Mark(err, errMaybeDelivered)
+	case code >= http.StatusBadRequest:
+		return errors.Mark(err, errPermanent)
+	}
+
+	return err
+}
+
+// telegramCodePattern finds the code telebot appends to API errors it has no type for.
+var telegramCodePattern = regexp.MustCompile(`\((\d{3})\)$`)
+
+// telegramCode returns the HTTP-like code of a Bot API error, zero if there is none.
+func telegramCode(err error) int {
+	var apiErr *tb.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+
+	var floodErr tb.FloodError
+	if errors.As(err, &floodErr) {
+		return http.StatusTooManyRequests
+	}
+
+	var groupErr tb.GroupError
+	if errors.As(err, &groupErr) {
+		return http.StatusBadRequest
+	}
+
+	match := telegramCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0
+	}
+
+	code, _ := strconv.Atoi(match[1])
+
+	return code
+}
+
+// idempotencyKey identifies the delivery of the post by the route, it is the same on every retry.
+func idempotencyKey(message *outgoing) string {
+	return "vk2tg-" + message.route.Name + "-" + strconv.Itoa(message.post.OwnerID) + "_" + strconv.Itoa(message.post.ID)
+}
+
+// requestSent reports whether a failed request may have reached the server,
+// only failures to connect are sure to have sent nothing.
+func requestSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+
+	return !errors.As(err, &dnsErr)
+}
+
+// sendJSON sends the payload and classifies the response: rejected requests are permanent
+// failures, rate limits are worth retrying and server errors may have been delivered.
+func sendJSON(ctx context.Context, method, url string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Mark(errors.Wrap(err, "can't encode payload"), errPermanent)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Mark(errors.Wrap(err, "cannot create request"), errPermanent)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "request failed")
+		if requestSent(err) {
+			return errors.Mark(err, errMaybeDelivered)
+		}
+
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	text, _ := io.ReadAll(io.LimitReader(resp.Body, sinkErrorBodyLimit))
+	err = errors.Newf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(text)))
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.Mark(err, errMaybeDelivered)
+	}
+
+	return errors.Mark(err, errPermanent)
+}
@@ -0,0 +1,503 @@
+package vk2tg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+func testOutgoing(t *testing.T) *outgoing {
+	t.Helper()
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.sources = []Source{&VKSource{Name: "poisk", OwnerID: -1}}
+	vtCli.cacheProfile(profile{ID: -1, Name: "Poisk", URL: "https://vk.com/poisk"})
+	vtCli.cacheProfile(profile{ID: 7, Name: "Anna", URL: "https://vk.com/id7"})
+
+	post := &vkObject.WallWallpost{ID: 5, OwnerID: -1, SignerID: 7, Date: 1700000000, Text: "Lost cat"}
+
+	return vtCli.newOutgoing(&Route{Name: "search"}, post, false)
+}
+
+// TestHTTPSinks tests the requests of the HTTP based sinks against a stand-in server.
+func TestHTTPSinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		sink   func(url string) *SinkConfig
+		method string
+		path   string
+		header string
+		fields map[string]any
+	}{
+		{
+			name: "webhook",
+			sink: func(url string) *SinkConfig {
+				return &SinkConfig{Name: "hook", Webhook: &WebhookSink{URL: url + "/hook", Headers: map[string]string{"X-Token": "secret"}}}
+			},
+			method: http.MethodPost,
+			path:   "/hook",
+			header: "X-Token",
+			fields: map[string]any{"route": "search", "source": "poisk", "text": "Lost cat", "url": "https://vk.com/wall-1_5"},
+		},
+		{
+			name: "discord",
+			sink: func(url string) *SinkConfig {
+				return &SinkConfig{Name: "discord", Discord: &DiscordSink{URL: url + "/api/webhooks/1/token", Username: "vk2tg"}}
+			},
+			method: http.MethodPost,
+			path:   "/api/webhooks/1/token",
+			fields: map[string]any{"username": "vk2tg"},
+		},
+		{
+			name: "matrix",
+			sink: func(url string) *SinkConfig {
+				return &SinkConfig{Name: "matrix", Matrix: &MatrixSink{Homeserver: url, RoomID: "!room:example.org", Token: "secret"}}
+			},
+			method: http.MethodPut,
+			path:   "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/vk2tg-search--1_5",
+			header: "Authorization",
+			fields: map[string]any{"msgtype": "m.text", "body": "Lost cat\n\nhttps://vk.com/wall-1_5"},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			var got map[string]any
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if request.Method != testCase.method || request.URL.Path != testCase.path {
+					t.Errorf("unexpected request %s %s", request.Method, request.URL.Path)
+				}
+
+				if testCase.header != "" && request.Header.Get(testCase.header) == "" {
+					t.Errorf("expected header %s", testCase.header)
+				}
+
+				err := json.NewDecoder(request.Body).Decode(&got)
+				if err != nil {
+					t.Errorf("can't decode body: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			config := testCase.sink(server.URL)
+
+			err := config.init()
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			err = config.send(testOutgoing(t))
+			if err != nil {
+				t.Fatalf("failed to send: %v", err)
+			}
+
+			for field, expected := range testCase.fields {
+				if got[field] != expected {
+					t.Errorf("expected %s %q, got %q", field, expected, got[field])
+				}
+			}
+		})
+	}
+}
+
+// TestSinkRetry tests that only temporary failures are retried.
+func TestSinkRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		calls    int32
+		wantErr  bool
+	}{
+		{"server error is retried", []int{http.StatusBadGateway, http.StatusOK}, 2, false},
+		{"rate limit is retried until attempts run out", []int{429, 429, 429, 429}, 3, true},
+		{"rejected request is not retried", []int{http.StatusBadRequest, http.StatusOK}, 1, true},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(testCase.statuses[calls.Add(1)-1])
+			}))
+			defer server.Close()
+
+			config := &SinkConfig{
+				Name:    "hook",
+				Retry:   Retry{Attempts: 3, Backoff: time.Millisecond},
+				Webhook: &WebhookSink{URL: server.URL},
+			}
+
+			err := config.init()
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			err = config.send(testOutgoing(t))
+			if (err != nil) != testCase.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if calls.Load() != testCase.calls {
+				t.Errorf("expected %d calls, got %d", testCase.calls, calls.Load())
+			}
+		})
+	}
+}
+
+// TestSinkRetryAfterDelivery tests that server errors are only retried by sinks that drop repeats.
+func TestSinkRetryAfterDelivery(t *testing.T) {
+	tests := []struct {
+		name  string
+		sink  func(url string) *SinkConfig
+		calls int32
+	}{
+		{
+			name:  "webhook retries with the same key",
+			sink:  func(url string) *SinkConfig { return &SinkConfig{Name: "hook", Webhook: &WebhookSink{URL: url}} },
+			calls: 2,
+		},
+		{
+			name:  "discord doesn't post twice",
+			sink:  func(url string) *SinkConfig { return &SinkConfig{Name: "discord", Discord: &DiscordSink{URL: url}} },
+			calls: 1,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				calls atomic.Int32
+				keys  sync.Map
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				keys.Store(request.Header.Get("Idempotency-Key"), true)
+
+				if calls.Add(1) == 1 {
+					writer.WriteHeader(http.StatusBadGateway)
+				}
+			}))
+			defer server.Close()
+
+			config := testCase.sink(server.URL)
+			config.Retry = Retry{Attempts: 3, Backoff: time.Millisecond}
+
+			err := config.init()
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			err = config.send(testOutgoing(t))
+			if calls.Load() != testCase.calls || (err == nil) != (testCase.calls == 2) {
+				t.Errorf("expected %d calls, got %d, %v", testCase.calls, calls.Load(), err)
+			}
+
+			if config.Webhook == nil {
+				return
+			}
+
+			count := 0
+
+			keys.Range(func(key, _ any) bool {
+				count++
+
+				if key != "vk2tg-search--1_5" {
+					t.Errorf("unexpected idempotency key %q", key)
+				}
+
+				return true
+			})
+
+			if count != 1 {
+				t.Errorf("expected one idempotency key, got %d", count)
+			}
+		})
+	}
+}
+
+// TestEmailSink tests the SMTP conversation against a minimal stand-in server.
+func TestEmailSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		received <- fakeSMTP(conn, "250 queued")
+	}()
+
+	config := &SinkConfig{
+		Name:  "mail",
+		Email: &EmailSink{Addr: listener.Addr().String(), From: "bot@example.org", To: []string{"team@example.org"}},
+	}
+
+	err = config.init()
+	if err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	err = config.send(testOutgoing(t))
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	mail := <-received
+
+	for _, expected := range []string{"MAIL FROM:<bot@example.org>", "RCPT TO:<team@example.org>", "Subject: poisk: Lost cat", "https://vk.com/wall-1_5"} {
+		if !strings.Contains(mail, expected) {
+			t.Errorf("expected %q in the conversation:\n%s", expected, mail)
+		}
+	}
+}
+
+// fakeSMTP accepts a single mail, answering its end with queued,
+// and returns the whole client side of the conversation.
+func fakeSMTP(conn net.Conn, queued string) string {
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	var conversation strings.Builder
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return conversation.String()
+		}
+
+		conversation.WriteString(line)
+
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250 localhost")
+		case command == "DATA":
+			reply("354 go ahead")
+
+			for {
+				line, err = reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+
+				conversation.WriteString(line)
+			}
+
+			reply(queued)
+		case command == "QUIT":
+			reply("221 bye")
+
+			return conversation.String()
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// TestSinkDeliveries tests that a route reports the sinks it delivered to and only retries
+// sinks that failed for a reason that may go away.
+func TestSinkDeliveries(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = map[string]int{}
+	)
+
+	statuses := map[string]int{
+		"/good":     http.StatusOK,
+		"/busy":     http.StatusTooManyRequests,
+		"/rejected": http.StatusBadRequest,
+		"/flaky":    http.StatusBadGateway,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		calls[req.URL.Path]++
+		mu.Unlock()
+
+		writer.WriteHeader(statuses[req.URL.Path])
+	}))
+	defer server.Close()
+
+	route := &Route{Name: "partners"}
+
+	for _, config := range []*SinkConfig{
+		{Name: "good", Webhook: &WebhookSink{URL: server.URL + "/good"}},
+		{Name: "busy", Webhook: &WebhookSink{URL: server.URL + "/busy"}},
+		{Name: "rejected", Webhook: &WebhookSink{URL: server.URL + "/rejected"}},
+		// Discord may post twice, so a server error counts as delivered.
+		{Name: "flaky", Discord: &DiscordSink{URL: server.URL + "/flaky"}},
+	} {
+		config.Retry = Retry{Attempts: 1}
+
+		err := config.init()
+		if err != nil {
+			t.Fatalf("failed to init: %v", err)
+		}
+
+		route.sinks = append(route.sinks, config)
+	}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	post := &vkObject.WallWallpost{ID: 5, OwnerID: -1, Text: "Lost cat"}
+
+	deliveries, err := vtCli.send(route, post, false, nil)
+	if err == nil || !delivered(deliveries, "partners", "good") || !delivered(deliveries, "partners", "flaky") || len(deliveries) != 2 {
+		t.Fatalf("unexpected deliveries %v, %v", deliveries, err)
+	}
+
+	_, err = vtCli.send(route, post, false, deliveries)
+	if err == nil {
+		t.Error("expected the busy sink to fail again")
+	}
+
+	expected := map[string]int{"/good": 1, "/busy": 2, "/rejected": 2, "/flaky": 1}
+	if !maps.Equal(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+}
+
+// TestEmailSinkRejected tests that a mail refused with a 5xx reply is not retried and one refused with 4xx is.
+func TestEmailSinkRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		permanent bool
+	}{
+		{"rejected", "554 spam detected", true},
+		{"greylisted", "451 try again later", false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				fakeSMTP(conn, testCase.reply)
+			}()
+
+			config := &SinkConfig{
+				Name:  "mail",
+				Email: &EmailSink{Addr: listener.Addr().String(), From: "bot@example.org", To: []string{"team@example.org"}},
+				Retry: Retry{Attempts: 1},
+			}
+
+			err = config.init()
+			if err != nil {
+				t.Fatalf("failed to init: %v", err)
+			}
+
+			err = config.send(testOutgoing(t))
+			if err == nil || errors.Is(err, errPermanent) != testCase.permanent || errors.Is(err, errMaybeDelivered) {
+				t.Errorf("expected permanent %t, got %v", testCase.permanent, err)
+			}
+		})
+	}
+}
+
+// TestClassifyTelegram tests that rejected Bot API calls are permanent and lost ones may have been delivered.
+func TestClassifyTelegram(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		permanent bool
+		maybe     bool
+	}{
+		{"known API error", errors.Wrap(tb.ErrChatNotFound, "can't send post"), true, false},
+		{"other API error", fmt.Errorf("telegram: %s (%d)", "Forbidden: bot was kicked", 403), true, false},
+		{"rate limited", fmt.Errorf("telegram: %s (%d)", "Too Many Requests", 429), false, false},
+		{"server error", fmt.Errorf("telegram: %s (%d)", "Bad Gateway", 502), false, true},
+		{"connection lost", fmt.Errorf("telebot: %w", &net.OpError{Op: "read", Err: io.ErrUnexpectedEOF}), false, true},
+		{"connection refused", fmt.Errorf("telebot: %w", &net.OpError{Op: "dial", Err: io.EOF}), false, false},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := classifyTelegram(testCase.err)
+			if errors.Is(err, errPermanent) != testCase.permanent || errors.Is(err, errMaybeDelivered) != testCase.maybe {
+				t.Errorf("expected permanent %t and maybe delivered %t, got %v", testCase.permanent, testCase.maybe, err)
+			}
+		})
+	}
+}
+
+// TestTelegramSinkTemplate tests that a Telegram sink renders with its own template.
+func TestTelegramSinkTemplate(t *testing.T) {
+	var sent atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		params := make(map[string]string)
+		_ = json.NewDecoder(request.Body).Decode(&params)
+		sent.Store(params)
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":-200}}}`))
+	}))
+	defer server.Close()
+
+	message := testOutgoing(t)
+
+	bot, err := tb.NewBot(tb.Settings{Token: "token", URL: server.URL, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &TelegramSink{ChatID: -200, vtCli: NewVTClient("", "", 0, 1)}
+	sink.vtCli.tgClient = bot
+
+	config := &SinkConfig{Name: "mirror", Telegram: sink, Template: &Template{ParseMode: "html", Body: "<b>{{ .Text | escape }}</b>"}}
+
+	err = config.init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	custom, err := message.forSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = config.send(custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, _ := sent.Load().(map[string]string)
+	if params["text"] != "<b>Lost cat</b>" || params["parse_mode"] != "HTML" || params["chat_id"] != "-200" {
+		t.Errorf("expected the sink template, got %v", params)
+	}
+}
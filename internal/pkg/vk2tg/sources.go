@@ -0,0 +1,169 @@
+package vk2tg
+
+import (
+	"strconv"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+)
+
+// defaultOwnerID is the VK wall watched when no sources are configured.
+const defaultOwnerID = -57692133
+
+// wallFetchCount is the number of latest posts requested on every poll.
+const wallFetchCount = 10
+
+// Source is a feed of posts for the pipeline.
+// Posts of every source are VK wall posts, other sources convert their items.
+type Source interface {
+	name() string
+	// owner is the VK owner ID of the source, non-VK sources get a synthetic one.
+	owner() int
+	// load restores the position of the source from storage.
+	load(store storage) error
+	// fetch returns posts not forwarded yet, oldest first.
+	fetch(vtCli *VTClinent) ([]*vkObject.WallWallpost, error)
+	// markSeen records the post as forwarded.
+	markSeen(store storage, post *vkObject.WallWallpost) error
+	// state is the error state of the source.
+	state() *sourceHealth
+}
+
+// VKSource is a VK wall watched for new posts.
+type VKSource struct {
+	Name    string `yaml:"name"`
+	OwnerID int    `yaml:"ownerId"`
+
+	lastPostID int
+	health     sourceHealth
+}
+
+func defaultSource() *VKSource {
+	return &VKSource{Name: "default", OwnerID: defaultOwnerID}
+}
+
+func defaultSources() []Source {
+	return []Source{defaultSource()}
+}
+
+// sourceName returns the configured name of the source the post comes from.
+func (vtCli *VTClinent) sourceName(ownerID int) string {
+	if source := vtCli.source(ownerID); source != nil {
+		return source.name()
+	}
+
+	return strconv.Itoa(ownerID)
+}
+
+// source returns the source with the owner ID, nil if there is none.
+func (vtCli *VTClinent) source(ownerID int) Source {
+	for _, source := range vtCli.sources {
+		if source.owner() == ownerID {
+			return source
+		}
+	}
+
+	return nil
+}
+
+// fetchSource sends new posts of the source to the pipeline, oldest first.
+func (vtCli *VTClinent) fetchSource(source Source) error {
+	posts, err := source.fetch(vtCli)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		if !vtCli.fenced() {
+			vtCli.logger.Printf("Post %d: Leader lease lost, left for the new leader", post.ID)
+
+			return nil
+		}
+
+		vtCli.logger.Printf("Post %d: Selected as latest in %s", post.ID, source.name())
+
+		// Not forwarding a post that can't be marked as seen keeps it from being sent twice after a restart,
+		// it is retried on the next poll instead.
+		err = source.markSeen(vtCli.storage, post)
+		if err != nil {
+			return errors.Wrapf(err, "post %d", post.ID)
+		}
+
+		vtCli.config.LastPostDate = post.Date
+
+		vtCli.logger.Printf("Post %d: Sending to routes", post.ID)
+
+		vtCli.chVKPosts <- post
+	}
+
+	return nil
+}
+
+func (source *VKSource) name() string {
+	return source.Name
+}
+
+func (source *VKSource) owner() int {
+	return source.OwnerID
+}
+
+func (source *VKSource) state() *sourceHealth {
+	return &source.health
+}
+
+func (source *VKSource) load(store storage) error {
+	lastPostID, err := store.GetLastPost(source.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	source.lastPostID = lastPostID
+
+	return nil
+}
+
+func (source *VKSource) fetch(vtCli *VTClinent) ([]*vkObject.WallWallpost, error) {
+	vkWall, err := vtCli.vkClient.WallGet(
+		vkapi.Params{
+			"owner_id": source.OwnerID,
+			"count":    wallFetchCount,
+		},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch posts")
+	}
+
+	if len(vkWall.Items) == 0 || vkWall.Items[0].ID == source.lastPostID {
+		return nil, nil
+	}
+
+	var posts []*vkObject.WallWallpost
+
+	for index := len(vkWall.Items) - 1; index >= 0; index-- {
+		post := &vkWall.Items[index]
+
+		vtCli.logger.Printf("Post %d: Processing", post.ID)
+
+		if source.lastPostID >= post.ID {
+			vtCli.logger.Printf("Post %d: Not a new post, skipped", post.ID)
+
+			continue
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+func (source *VKSource) markSeen(store storage, post *vkObject.WallWallpost) error {
+	err := store.SetLastPost(source.OwnerID, post.ID)
+	if err != nil {
+		return err
+	}
+
+	source.lastPostID = post.ID
+
+	return nil
+}
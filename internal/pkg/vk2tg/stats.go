@@ -0,0 +1,320 @@
+package vk2tg
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// statsRetention is how long daily counters are kept.
+	statsRetention = 92 * 24 * time.Hour
+	// statsDefaultDays is the period /stats covers without an argument.
+	statsDefaultDays = 7
+	// statsMaxDays is the longest period /stats and the HTTP export cover.
+	statsMaxDays   = 90
+	statsDayLayout = "2006-01-02"
+	// statsNameWidth is the width of the name column of /stats.
+	statsNameWidth = 14
+)
+
+// Dimensions of the statistics.
+const (
+	statsSources = "source"
+	statsRoutes  = "route"
+	statsFilters = "filter"
+)
+
+// Counters of the statistics.
+const (
+	counterPosts      = "posts"
+	counterMatched    = "matched"
+	counterDuplicates = "duplicates"
+	counterDelivered  = "delivered"
+	counterFailed     = "failed"
+)
+
+// statsCounters lists the counters kept for every dimension.
+var statsCounters = map[string][]string{
+	statsSources: {counterPosts, counterMatched, counterDuplicates},
+	statsRoutes:  {counterMatched, counterDelivered, counterFailed},
+	statsFilters: {counterMatched},
+}
+
+// noFilter names routes without a filter in the statistics.
+const noFilter = "(none)"
+
+// counters maps counter names to values.
+type counters map[string]int64
+
+// statsBucket holds the counters of a day or a period, by dimension and name.
+type statsBucket struct {
+	Date    string              `json:"date,omitempty"`
+	Sources map[string]counters `json:"sources"`
+	Routes  map[string]counters `json:"routes"`
+	Filters map[string]counters `json:"filters"`
+}
+
+// statsReport is the statistics of a period as exported over HTTP.
+type statsReport struct {
+	Since string        `json:"since"`
+	Until string        `json:"until"`
+	Days  []statsBucket `json:"days"`
+	Total statsBucket   `json:"total"`
+}
+
+func newStatsBucket(date string) statsBucket {
+	return statsBucket{
+		Date:    date,
+		Sources: make(map[string]counters),
+		Routes:  make(map[string]counters),
+		Filters: make(map[string]counters),
+	}
+}
+
+func (bucket *statsBucket) dimension(name string) map[string]counters {
+	switch name {
+	case statsSources:
+		return bucket.Sources
+	case statsRoutes:
+		return bucket.Routes
+	default:
+		return bucket.Filters
+	}
+}
+
+func (bucket *statsBucket) add(dimension, name, counter string, value int64) {
+	byName := bucket.dimension(dimension)
+	if byName[name] == nil {
+		byName[name] = make(counters)
+	}
+
+	byName[name][counter] += value
+}
+
+func statsKey(day time.Time, dimension, name, counter string) string {
+	return "stats:" + day.In(zone).Format(statsDayLayout) + ":" + dimension + ":" + name + ":" + counter
+}
+
+func filterName(route *Route) string {
+	if route.Filter == "" {
+		return noFilter
+	}
+
+	return route.Filter
+}
+
+// count adds to a counter of today.
+func (vtCli *VTClinent) count(dimension, name, counter string, delta int64) {
+	_, err := vtCli.storage.IncrBy(statsKey(time.Now(), dimension, name, counter), delta, statsRetention)
+	if err != nil {
+		vtCli.logger.Printf("Can't count %s of %s %s: %s", counter, dimension, name, err)
+	}
+}
+
+// countDelivery counts a delivery of the route, to its chat or a sink.
+func (vtCli *VTClinent) countDelivery(route *Route, err error) {
+	if err != nil {
+		vtCli.count(statsRoutes, route.Name, counterFailed, 1)
+
+		return
+	}
+
+	vtCli.count(statsRoutes, route.Name, counterDelivered, 1)
+}
+
+// statsNames returns the names of every source, route and filter, by dimension.
+func (vtCli *VTClinent) statsNames() map[string][]string {
+	names := map[string][]string{}
+
+	for _, source := range vtCli.sources {
+		names[statsSources] = append(names[statsSources], source.name())
+	}
+
+	for _, route := range vtCli.routes {
+		names[statsRoutes] = append(names[statsRoutes], route.Name)
+
+		if filter := filterName(route); !slices.Contains(names[statsFilters], filter) {
+			names[statsFilters] = append(names[statsFilters], filter)
+		}
+	}
+
+	return names
+}
+
+// stats reads the counters of the last days, today included.
+func (vtCli *VTClinent) stats(days int) (*statsReport, error) {
+	names := vtCli.statsNames()
+	now := time.Now().In(zone)
+
+	report := &statsReport{
+		Since: now.AddDate(0, 0, 1-days).Format(statsDayLayout),
+		Until: now.Format(statsDayLayout),
+		Total: newStatsBucket(""),
+	}
+
+	// Every counter of the period is read at once, cells remember where each value goes.
+	type cell struct {
+		bucket                   int
+		dimension, name, counter string
+	}
+
+	var (
+		keys  []string
+		cells []cell
+	)
+
+	for offset := days - 1; offset >= 0; offset-- {
+		day := now.AddDate(0, 0, -offset)
+		report.Days = append(report.Days, newStatsBucket(day.Format(statsDayLayout)))
+
+		for dimension, counterNames := range statsCounters {
+			for _, name := range names[dimension] {
+				for _, counter := range counterNames {
+					keys = append(keys, statsKey(day, dimension, name, counter))
+					cells = append(cells, cell{len(report.Days) - 1, dimension, name, counter})
+				}
+			}
+		}
+	}
+
+	values, err := vtCli.storage.GetMany(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, cell := range cells {
+		value, err := parseCounter(keys[index], values[index])
+		if err != nil {
+			return nil, err
+		}
+
+		report.Days[cell.bucket].add(cell.dimension, cell.name, cell.counter, value)
+		report.Total.add(cell.dimension, cell.name, cell.counter, value)
+	}
+
+	return report, nil
+}
+
+// parseCounter decodes a counter read from storage, a missing one is 0.
+func parseCounter(key string, data []byte) (int64, error) {
+	if data == nil {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid counter %s", key)
+	}
+
+	return value, nil
+}
+
+// statsTable renders the totals of the report as a fixed-width table.
+func (report *statsReport) statsTable(lang string) string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "📊 %s — %s\n", report.Since, report.Until)
+
+	for _, section := range []struct {
+		title     string
+		dimension string
+	}{
+		{"stats.sources", statsSources},
+		{"stats.routes", statsRoutes},
+		{"stats.filters", statsFilters},
+	} {
+		byName := report.Total.dimension(section.dimension)
+		if len(byName) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&builder, "\n%-*s", statsNameWidth, translate(lang, section.title))
+
+		// Column headers are stats.<counter> in the catalog, at most 5 characters long.
+		for _, counter := range statsCounters[section.dimension] {
+			fmt.Fprintf(&builder, "%6s", translate(lang, "stats."+counter))
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(byName)) {
+			fmt.Fprintf(&builder, "\n%-*s", statsNameWidth, truncate(statsNameWidth, name))
+
+			for _, counter := range statsCounters[section.dimension] {
+				fmt.Fprintf(&builder, "%6d", byName[name][counter])
+			}
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+func (vtCli *VTClinent) statsCommand(tbContext tb.Context) error {
+	if !vtCli.isAdmin(tbContext.Sender()) {
+		return nil
+	}
+
+	days := statsDefaultDays
+
+	if arg := tbContext.Message().Payload; arg != "" {
+		parsed, err := strconv.Atoi(arg)
+		if err != nil || parsed <= 0 {
+			return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "stats.usage")), "error on sending message")
+		}
+
+		days = min(parsed, statsMaxDays)
+	}
+
+	report, err := vtCli.stats(days)
+	if err != nil {
+		vtCli.logger.Printf("Can't read stats: %s", err)
+
+		return errors.Wrap(tbContext.Send(vtCli.tr(tbContext, "stats.error")), "error on sending message")
+	}
+
+	return errors.Wrap(
+		tbContext.Send("<pre>"+html.EscapeString(report.statsTable(vtCli.userLanguage(tbContext)))+"</pre>", tb.ModeHTML),
+		"error on sending message",
+	)
+}
+
+// statsJSON serves the daily counters, ?days= sets the period.
+func (vtCli *VTClinent) statsJSON(writer http.ResponseWriter, req *http.Request) {
+	days := statsDefaultDays
+
+	if arg := req.URL.Query().Get("days"); arg != "" {
+		parsed, err := strconv.Atoi(arg)
+		if err != nil || parsed <= 0 {
+			http.Error(writer, "days must be a positive number", http.StatusBadRequest)
+
+			return
+		}
+
+		days = min(parsed, statsMaxDays)
+	}
+
+	report, err := vtCli.stats(days)
+	if err != nil {
+		vtCli.logger.Printf("Can't read stats: %s", err)
+		http.Error(writer, "can't read stats", http.StatusInternalServerError)
+
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(writer).Encode(report)
+	if err != nil {
+		vtCli.logger.Printf("Can't write stats: %s", err)
+	}
+}
@@ -0,0 +1,132 @@
+package vk2tg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStats tests that daily counters add up in the report and the table.
+func TestStats(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.sources = []Source{&VKSource{Name: "poisk", OwnerID: -1}}
+	vtCli.routes = []*Route{{Name: "search", Filter: "#поиск"}, {Name: "all"}}
+
+	vtCli.count(statsSources, "poisk", counterPosts, 3)
+	vtCli.count(statsSources, "poisk", counterMatched, 2)
+	vtCli.count(statsRoutes, "search", counterMatched, 2)
+	vtCli.countDelivery(vtCli.routes[0], nil)
+	vtCli.countDelivery(vtCli.routes[0], errPermanent)
+
+	// A counter of yesterday only shows up in longer periods.
+	_, err := vtCli.storage.IncrBy(statsKey(time.Now().AddDate(0, 0, -1), statsSources, "poisk", counterPosts), 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		days  int
+		posts int64
+	}{
+		{"today", 1, 3},
+		{"week", 7, 7},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			report, err := vtCli.stats(testCase.days)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(report.Days) != testCase.days {
+				t.Errorf("expected %d days, got %d", testCase.days, len(report.Days))
+			}
+
+			if posts := report.Total.Sources["poisk"][counterPosts]; posts != testCase.posts {
+				t.Errorf("expected %d posts, got %d", testCase.posts, posts)
+			}
+
+			route := report.Total.Routes["search"]
+			if route[counterDelivered] != 1 || route[counterFailed] != 1 {
+				t.Errorf("unexpected route counters %v", route)
+			}
+
+			if _, ok := report.Total.Filters[noFilter]; !ok {
+				t.Errorf("routes without a filter are missing from %v", report.Total.Filters)
+			}
+		})
+	}
+
+	report, err := vtCli.stats(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if table := report.statsTable(langEN); !strings.Contains(table, "search             2     1     1") {
+		t.Errorf("unexpected table:\n%s", table)
+	}
+}
+
+// readCountingStorage counts storage reads.
+type readCountingStorage struct {
+	storage
+
+	gets, batches int
+}
+
+func (counting *readCountingStorage) Get(key string) ([]byte, error) {
+	counting.gets++
+
+	return counting.storage.Get(key)
+}
+
+func (counting *readCountingStorage) GetMany(keys []string) ([][]byte, error) {
+	counting.batches++
+
+	return counting.storage.GetMany(keys)
+}
+
+// TestStatsBatch tests that the report is read in one batch and the table headers are translated.
+func TestStatsBatch(t *testing.T) {
+	reads := &readCountingStorage{storage: newMemoryStorage()}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.storage = reads
+	vtCli.sources = []Source{&VKSource{Name: "poisk", OwnerID: -1}}
+	vtCli.routes = []*Route{{Name: "search", Filter: "#поиск"}}
+
+	vtCli.count(statsSources, "poisk", counterPosts, 5)
+	vtCli.count(statsSources, "poisk", counterDuplicates, 1)
+
+	report, err := vtCli.stats(statsMaxDays)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reads.gets != 0 || reads.batches != 1 {
+		t.Errorf("expected a single batch read, got %d reads and %d batches", reads.gets, reads.batches)
+	}
+
+	if posts := report.Days[len(report.Days)-1].Sources["poisk"][counterPosts]; posts != 5 {
+		t.Errorf("expected 5 posts today, got %d", posts)
+	}
+
+	tests := []struct {
+		lang   string
+		header string
+	}{
+		{langEN, "Source         posts match   dup"},
+		{langRU, "Источник       посты  совп дубли"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.lang, func(t *testing.T) {
+			table := report.statsTable(testCase.lang)
+			if !strings.Contains(table, testCase.header+"\npoisk              5     0     1") {
+				t.Errorf("unexpected table:\n%s", table)
+			}
+		})
+	}
+}
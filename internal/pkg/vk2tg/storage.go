@@ -2,16 +2,45 @@ package vk2tg
 
 import (
 	"context"
-	"log"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/redis/go-redis/v9"
 )
 
+// errNotFound is returned by storage when a key doesn't exist.
+var errNotFound = errors.New("not found")
+
 type storage interface {
-	GetLastPost() int
-	SetLastPost(postID int)
+	// GetLastPost returns the ID of the last forwarded post of the wall, 0 if there is none.
+	GetLastPost(ownerID int) (int, error)
+	SetLastPost(ownerID, postID int) error
+
+	// Get returns the value stored under key or errNotFound.
+	Get(key string) ([]byte, error)
+	// GetMany returns the values stored under keys in one round trip, nil for missing keys.
+	GetMany(keys []string) ([][]byte, error)
+	// Set stores the value under key, ttl of 0 means no expiration.
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+
+	// Push prepends the value to the list under key, keeping at most limit items.
+	Push(key string, value []byte, limit int) error
+	// Range returns up to count latest items of the list under key, count of 0 means all.
+	Range(key string, count int) ([][]byte, error)
+
+	// Acquire stores the value under key unless the key exists and reports whether it did.
+	Acquire(key string, value []byte, ttl time.Duration) (bool, error)
+	// Renew extends the ttl of key if it still holds value and reports whether it did.
+	Renew(key string, value []byte, ttl time.Duration) (bool, error)
+	// Release deletes key if it still holds value.
+	Release(key string, value []byte) error
+	// IncrBy adds delta to the counter under key and returns its new value,
+	// ttl of a new counter of 0 means no expiration.
+	IncrBy(key string, delta int64, ttl time.Duration) (int64, error)
 }
 
 type redisStorage struct {
@@ -19,64 +48,293 @@ type redisStorage struct {
 	cli         *redis.Client
 }
 
-func (redisStorage *redisStorage) GetLastPost() int {
-	res, err := redisStorage.cli.Get(context.TODO(), "LastPost").Result()
+// lastPostKey is the key of the last post ID of a wall, followed by its owner ID.
+// Alone it is the legacy key from the time only one wall was watched.
+const lastPostKey = "LastPost"
+
+func (redisStorage *redisStorage) GetLastPost(ownerID int) (int, error) {
+	key := lastPostKey + ":" + strconv.Itoa(ownerID)
+
+	// Keys used to lack the service prefix, they are read until the next post overwrites them.
+	keys := []string{redisStorage.key(key), key}
+	if ownerID == defaultOwnerID {
+		keys = append(keys, lastPostKey)
+	}
+
+	var (
+		res string
+		err error
+	)
+
+	for _, candidate := range keys {
+		res, err = redisStorage.cli.Get(context.TODO(), candidate).Result()
+		if !errors.Is(err, redis.Nil) {
+			break
+		}
+	}
+
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
 	if err != nil {
-		return 0
+		return 0, errors.Wrapf(err, "can't get last post of %d", ownerID)
 	}
 
 	postID, err := strconv.Atoi(res)
 	if err != nil {
-		return 0
+		return 0, errors.Wrapf(err, "invalid last post of %d", ownerID)
 	}
 
-	return postID
+	return postID, nil
 }
 
-func (redisStorage *redisStorage) SetLastPost(postID int) {
-	_, err := redisStorage.cli.Set(context.TODO(), "LastPost", postID, 0).Result()
+func (redisStorage *redisStorage) SetLastPost(ownerID, postID int) error {
+	err := redisStorage.cli.Set(context.TODO(), redisStorage.key(lastPostKey+":"+strconv.Itoa(ownerID)), postID, 0).Err()
 	if err != nil {
-		log.Println(err)
+		return errors.Wrapf(err, "can't set last post of %d", ownerID)
+	}
 
-		return
+	return nil
+}
+
+func (redisStorage *redisStorage) key(key string) string {
+	return redisStorage.serviceName + ":" + key
+}
+
+func (redisStorage *redisStorage) Get(key string) ([]byte, error) {
+	res, err := redisStorage.cli.Get(context.TODO(), redisStorage.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errNotFound
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get %s", key)
 	}
+
+	return res, nil
 }
 
-func newRedisStorage(serviceName, addr, pass string) *redisStorage {
-	cli := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: pass,
-		DB:       0,
+func (redisStorage *redisStorage) GetMany(keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, redisStorage.key(key))
+	}
+
+	res, err := redisStorage.cli.MGet(context.TODO(), prefixed...).Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get %d keys", len(keys))
+	}
+
+	values := make([][]byte, len(res))
+	for index, value := range res {
+		if text, ok := value.(string); ok {
+			values[index] = []byte(text)
+		}
+	}
+
+	return values, nil
+}
+
+func (redisStorage *redisStorage) Set(key string, value []byte, ttl time.Duration) error {
+	err := redisStorage.cli.Set(context.TODO(), redisStorage.key(key), value, ttl).Err()
+	if err != nil {
+		return errors.Wrapf(err, "can't set %s", key)
+	}
+
+	return nil
+}
+
+func (redisStorage *redisStorage) Delete(key string) error {
+	err := redisStorage.cli.Del(context.TODO(), redisStorage.key(key)).Err()
+	if err != nil {
+		return errors.Wrapf(err, "can't delete %s", key)
+	}
+
+	return nil
+}
+
+func (redisStorage *redisStorage) Push(key string, value []byte, limit int) error {
+	_, err := redisStorage.cli.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.LPush(context.TODO(), redisStorage.key(key), value)
+		pipe.LTrim(context.TODO(), redisStorage.key(key), 0, int64(limit)-1)
+
+		return nil
 	})
+	if err != nil {
+		return errors.Wrapf(err, "can't push to %s", key)
+	}
 
-	rStorage := &redisStorage{
-		serviceName: serviceName,
-		cli:         cli,
+	return nil
+}
+
+func (redisStorage *redisStorage) Range(key string, count int) ([][]byte, error) {
+	res, err := redisStorage.cli.LRange(context.TODO(), redisStorage.key(key), 0, int64(count)-1).Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read %s", key)
 	}
 
-	pong := ""
+	items := make([][]byte, 0, len(res))
+	for _, item := range res {
+		items = append(items, []byte(item))
+	}
+
+	return items, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+func (redisStorage *redisStorage) Acquire(key string, value []byte, ttl time.Duration) (bool, error) {
+	ok, err := redisStorage.cli.SetNX(context.TODO(), redisStorage.key(key), value, ttl).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "can't acquire %s", key)
+	}
+
+	return ok, nil
+}
+
+// renewScript extends the ttl only if the key still holds the value, atomically.
+var renewScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the key only if it still holds the value, atomically.
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+func (redisStorage *redisStorage) Renew(key string, value []byte, ttl time.Duration) (bool, error) {
+	res, err := renewScript.Run(context.TODO(), redisStorage.cli, []string{redisStorage.key(key)}, value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, errors.Wrapf(err, "can't renew %s", key)
+	}
+
+	return res == 1, nil
+}
+
+func (redisStorage *redisStorage) Release(key string, value []byte) error {
+	err := releaseScript.Run(context.TODO(), redisStorage.cli, []string{redisStorage.key(key)}, value).Err()
+	if err != nil {
+		return errors.Wrapf(err, "can't release %s", key)
+	}
+
+	return nil
+}
+
+func (redisStorage *redisStorage) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	res, err := redisStorage.cli.IncrBy(context.TODO(), redisStorage.key(key), delta).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't increment %s", key)
+	}
+
+	// Only a new counter gets the ttl, so it expires at a fixed time however often it is incremented.
+	if ttl > 0 && res == delta {
+		err = redisStorage.cli.Expire(context.TODO(), redisStorage.key(key), ttl).Err()
+		if err != nil {
+			return 0, errors.Wrapf(err, "can't set expiration of %s", key)
+		}
+	}
+
+	return res, nil
+}
+
+const (
+	// redisStartupTimeout bounds the wait for Redis on start.
+	redisStartupTimeout = 30 * time.Second
+	// redisRetryPeriod is the pause between connection attempts on start.
+	redisRetryPeriod = time.Second
+)
+
+// newRedisClient connects by a redis:// or rediss:// URL with optional user, password and DB.
+// A master_name query parameter switches to Sentinel: the host and any addr parameters
+// are then sentinels and the master password goes in the password parameter.
+// It also returns a description of the server without credentials for logs.
+func newRedisClient(redisURL string) (*redis.Client, string, error) {
+	parsed, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "invalid Redis URL")
+	}
+
+	if parsed.Query().Has("master_name") {
+		options, err := redis.ParseFailoverURL(redisURL)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "invalid Redis Sentinel URL")
+		}
+
+		return redis.NewFailoverClient(options),
+			"master " + options.MasterName + " via sentinels " + strings.Join(options.SentinelAddrs, ","), nil
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "invalid Redis URL")
+	}
+
+	return redis.NewClient(options), options.Addr, nil
+}
+
+// newRedisStorage waits for Redis to answer, giving up after redisStartupTimeout.
+// Later connection losses are retried by the client on every command.
+func newRedisStorage(serviceName string, cli *redis.Client, server string) (*redisStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisStartupTimeout)
 	defer cancel()
 
-	for pong != "PONG" {
-		pong, _ = rStorage.cli.Ping(ctx).Result()
+	for {
+		err := cli.Ping(ctx).Err()
+		if err == nil {
+			return &redisStorage{serviceName: serviceName, cli: cli}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			closeErr := cli.Close()
+			if closeErr != nil {
+				err = errors.CombineErrors(err, closeErr)
+			}
+
+			return nil, errors.Wrapf(err, "redis at %s is not reachable after %s", server, redisStartupTimeout)
+		case <-time.After(redisRetryPeriod):
+		}
+	}
+}
+
+// WithRedis keeps state in Redis at the address, using DB 0.
+func (vtCli *VTClinent) WithRedis(serviceName, redisAddr, redisPassword string) (*VTClinent, error) {
+	cli := redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: redisPassword,
+	})
+
+	return vtCli.withRedisClient(serviceName, cli, redisAddr)
+}
 
-		time.Sleep(time.Second)
+// WithRedisURL keeps state in Redis described by the URL, see newRedisClient for the format.
+func (vtCli *VTClinent) WithRedisURL(serviceName, redisURL string) (*VTClinent, error) {
+	cli, server, err := newRedisClient(redisURL)
+	if err != nil {
+		return vtCli, err
 	}
 
-	return rStorage
+	return vtCli.withRedisClient(serviceName, cli, server)
 }
 
-func (vtCli *VTClinent) WithRedis(serviceName, redisAddr, redisPassword string) *VTClinent {
+func (vtCli *VTClinent) withRedisClient(serviceName string, cli *redis.Client, server string) (*VTClinent, error) {
+	rStorage, err := newRedisStorage(serviceName, cli, server)
+	if err != nil {
+		return vtCli, err
+	}
+
 	vtCli.config.serviceName = serviceName
 	vtCli.config.StorageEnabled = true
+	vtCli.storage = rStorage
 
-	vtCli.storage = newRedisStorage(serviceName, redisAddr, redisPassword)
-
-	vtCli.config.LastPostID = vtCli.storage.GetLastPost()
-
-	vtCli.logger.Printf("Connected to Redis at %s", redisAddr)
+	vtCli.logger.Printf("Connected to Redis at %s", server)
 
-	return vtCli
+	return vtCli, nil
 }
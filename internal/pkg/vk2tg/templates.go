@@ -0,0 +1,316 @@
+package vk2tg
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+// Template renders forwarded posts of a route.
+type Template struct {
+	ParseMode tb.ParseMode      `yaml:"parseMode"`
+	Header    string            `yaml:"header"`
+	Body      string            `yaml:"body"`
+	Footer    string            `yaml:"footer"`
+	Buttons   [][]ButtonPattern `yaml:"buttons"`
+
+	header  *template.Template
+	body    *template.Template
+	footer  *template.Template
+	buttons [][]compiledButton
+}
+
+// ButtonPattern is an inline URL button, both fields are templates.
+// Buttons rendering to an empty URL are skipped.
+type ButtonPattern struct {
+	Text string `yaml:"text"`
+	URL  string `yaml:"url"`
+}
+
+type compiledButton struct {
+	text *template.Template
+	url  *template.Template
+}
+
+// postView is the data passed to templates.
+type postView struct {
+	Post   *vkObject.WallWallpost
+	Text   string
+	URL    string
+	Date   time.Time
+	Author author
+	// Owner is the wall the post was published on.
+	Owner  author
+	Source string
+	Route  string
+	Filter string
+	// Language is the language of the chat, for the tr function.
+	Language string
+}
+
+// author is a VK user or community with its resolved name and profile link.
+type author struct {
+	ID   int
+	Name string
+	URL  string
+	// WriteURL opens a VK dialog with the author, empty for communities and unknown authors.
+	WriteURL string
+}
+
+// defaultTemplate keeps the original look: plain post text with links to the post and its author.
+var defaultTemplate = &Template{
+	Body: "{{ .Text }}",
+	Buttons: [][]ButtonPattern{
+		{
+			{Text: `{{ tr .Language "post.open" }}`, URL: "{{ .URL }}"},
+			{Text: `{{ tr .Language "post.write" }}`, URL: "{{ .Author.WriteURL }}"},
+		},
+	},
+}
+
+func init() {
+	err := defaultTemplate.init()
+	if err != nil {
+		panic(err)
+	}
+}
+
+var parseModes = map[string]tb.ParseMode{
+	"":           tb.ModeDefault,
+	"html":       tb.ModeHTML,
+	"markdown":   tb.ModeMarkdown,
+	"markdownv2": tb.ModeMarkdownV2,
+}
+
+// markdownEscaper escapes the characters that are markup in the legacy Markdown parse mode.
+var markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+// markdownV2Escaper escapes every character MarkdownV2 reserves.
+var markdownV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`, "`", "\\`",
+	">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// templateFuncs returns the template functions, escape follows the parse mode.
+func templateFuncs(parseMode tb.ParseMode) template.FuncMap {
+	escape := func(text string) string { return text }
+
+	switch parseMode {
+	case tb.ModeHTML:
+		escape = html.EscapeString
+	case tb.ModeMarkdown:
+		escape = markdownEscaper.Replace
+	case tb.ModeMarkdownV2:
+		escape = markdownV2Escaper.Replace
+	}
+
+	return template.FuncMap{
+		"escape":   escape,
+		"truncate": truncate,
+		"tr":       func(lang, key string) string { return translate(lang, key) },
+	}
+}
+
+func (tmpl *Template) init() error {
+	parseMode, ok := parseModes[strings.ToLower(string(tmpl.ParseMode))]
+	if !ok {
+		return errors.Newf("unknown parse mode %q", tmpl.ParseMode)
+	}
+
+	tmpl.ParseMode = parseMode
+	funcs := templateFuncs(parseMode)
+
+	if tmpl.Body == "" {
+		tmpl.Body = defaultTemplate.Body
+	}
+
+	var err error
+
+	for _, part := range []struct {
+		name   string
+		source string
+		target **template.Template
+	}{
+		{"header", tmpl.Header, &tmpl.header},
+		{"body", tmpl.Body, &tmpl.body},
+		{"footer", tmpl.Footer, &tmpl.footer},
+	} {
+		*part.target, err = template.New(part.name).Funcs(funcs).Parse(part.source)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", part.name)
+		}
+	}
+
+	tmpl.buttons = make([][]compiledButton, 0, len(tmpl.Buttons))
+
+	for _, row := range tmpl.Buttons {
+		compiledRow := make([]compiledButton, 0, len(row))
+
+		for _, button := range row {
+			text, err := template.New("button").Funcs(funcs).Parse(button.Text)
+			if err != nil {
+				return errors.Wrap(err, "invalid button text")
+			}
+
+			url, err := template.New("button").Funcs(funcs).Parse(button.URL)
+			if err != nil {
+				return errors.Wrap(err, "invalid button URL")
+			}
+
+			compiledRow = append(compiledRow, compiledButton{text: text, url: url})
+		}
+
+		tmpl.buttons = append(tmpl.buttons, compiledRow)
+	}
+
+	return nil
+}
+
+// render returns the message text and its inline keyboard.
+func (tmpl *Template) render(view *postView) (string, *tb.ReplyMarkup, error) {
+	var parts []string
+
+	for _, part := range []*template.Template{tmpl.header, tmpl.body, tmpl.footer} {
+		text, err := execute(part, view)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if strings.TrimSpace(text) != "" {
+			parts = append(parts, text)
+		}
+	}
+
+	markup := &tb.ReplyMarkup{}
+
+	for _, row := range tmpl.buttons {
+		var buttons []tb.InlineButton
+
+		for _, button := range row {
+			url, err := execute(button.url, view)
+			if err != nil {
+				return "", nil, err
+			}
+
+			if strings.TrimSpace(url) == "" {
+				continue
+			}
+
+			text, err := execute(button.text, view)
+			if err != nil {
+				return "", nil, err
+			}
+
+			buttons = append(buttons, tb.InlineButton{Text: text, URL: strings.TrimSpace(url)})
+		}
+
+		if len(buttons) > 0 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, buttons)
+		}
+	}
+
+	return strings.Join(parts, "\n\n"), markup, nil
+}
+
+func execute(tmpl *template.Template, view *postView) (string, error) {
+	var builder strings.Builder
+
+	err := tmpl.Execute(&builder, view)
+	if err != nil {
+		return "", errors.Wrapf(err, "can't render %s", tmpl.Name())
+	}
+
+	return builder.String(), nil
+}
+
+// newPostView collects everything templates may refer to.
+func (vtCli *VTClinent) newPostView(route *Route, post *vkObject.WallWallpost) *postView {
+	return &postView{
+		Post:   post,
+		Text:   post.Text,
+		URL:    postURL(post),
+		Date:   time.Unix(int64(post.Date), 0).In(zone),
+		Author: vtCli.postAuthor(post),
+		Owner:  vtCli.newAuthor(post.OwnerID),
+		Source: vtCli.sourceName(post.OwnerID),
+		Route:  route.Name,
+		Filter: route.Filter,
+
+		Language: vtCli.chatLanguage(route.ChatID),
+	}
+}
+
+// postAuthor returns the signer of a community post or the author of a user post.
+func (vtCli *VTClinent) postAuthor(post *vkObject.WallWallpost) author {
+	authorID := post.SignerID
+	if authorID == 0 && post.FromID > 0 {
+		authorID = post.FromID
+	}
+
+	if authorID <= 0 {
+		return author{}
+	}
+
+	return vtCli.newAuthor(authorID)
+}
+
+func (vtCli *VTClinent) newAuthor(id int) author {
+	resolved := vtCli.resolveName(id)
+
+	result := author{ID: resolved.ID, Name: resolved.Name, URL: resolved.URL}
+	if id > 0 {
+		result.WriteURL = "https://vk.com/write" + strconv.Itoa(id)
+	}
+
+	return result
+}
+
+// renderPost renders the post with the route template in the language of the chat.
+func (vtCli *VTClinent) renderPost(route *Route, chatID int64, post *vkObject.WallWallpost, silent bool) (string, *tb.SendOptions, error) {
+	return vtCli.renderPostWith(route, route.Template, chatID, post, silent)
+}
+
+// renderPostWith renders the post of the route with the template, the default one if nil.
+func (vtCli *VTClinent) renderPostWith(
+	route *Route, tmpl *Template, chatID int64, post *vkObject.WallWallpost, silent bool,
+) (string, *tb.SendOptions, error) {
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
+
+	view := vtCli.newPostView(route, post)
+	view.Language = vtCli.chatLanguage(chatID)
+
+	text, markup, err := tmpl.render(view)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if route.Actions != nil {
+		if row := route.Actions.buttons(post, view.Language); len(row) > 0 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+		}
+	}
+
+	return text, &tb.SendOptions{
+		ReplyMarkup:         markup,
+		ParseMode:           tmpl.ParseMode,
+		DisableNotification: silent,
+	}, nil
+}
+
+// truncate cuts text to limit runes, marking the cut with an ellipsis.
+func truncate(limit int, text string) string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	return string([]rune(text)[:limit-1]) + "…"
+}
@@ -0,0 +1,134 @@
+package vk2tg
+
+import (
+	"testing"
+
+	vkObject "github.com/SevereCloud/vksdk/v3/object"
+	tb "gopkg.in/telebot.v4"
+)
+
+// TestDefaultTemplateButtons tests that the write button is only rendered for a known author.
+func TestDefaultTemplateButtons(t *testing.T) {
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.cacheProfile(profile{ID: -1, Name: "Community", URL: "https://vk.com/community"})
+	vtCli.cacheProfile(profile{ID: 42, Name: "Ivan Petrov", URL: "https://vk.com/ivan"})
+
+	route := &Route{Name: "test"}
+
+	tests := []struct {
+		name     string
+		post     *vkObject.WallWallpost
+		expected []string
+	}{
+		{
+			name:     "community post without signer",
+			post:     &vkObject.WallWallpost{ID: 10, OwnerID: -1, FromID: -1, Text: "text"},
+			expected: []string{"https://vk.com/wall-1_10"},
+		},
+		{
+			name:     "signed community post",
+			post:     &vkObject.WallWallpost{ID: 11, OwnerID: -1, FromID: -1, SignerID: 42, Text: "text"},
+			expected: []string{"https://vk.com/wall-1_11", "https://vk.com/write42"},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			text, options, err := vtCli.renderPost(route, route.ChatID, testCase.post, false)
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+
+			if text != testCase.post.Text {
+				t.Errorf("expected text %q, got %q", testCase.post.Text, text)
+			}
+
+			var urls []string
+
+			for _, row := range options.ReplyMarkup.InlineKeyboard {
+				for _, button := range row {
+					urls = append(urls, button.URL)
+				}
+			}
+
+			if len(urls) != len(testCase.expected) {
+				t.Fatalf("expected buttons %v, got %v", testCase.expected, urls)
+			}
+
+			for index := range urls {
+				if urls[index] != testCase.expected[index] {
+					t.Errorf("expected button %q, got %q", testCase.expected[index], urls[index])
+				}
+			}
+		})
+	}
+}
+
+// TestCustomTemplate tests header, footer and helpers of a route template.
+func TestCustomTemplate(t *testing.T) {
+	tmpl := &Template{
+		ParseMode: "html",
+		Header:    "<b>{{ .Source | escape }}</b> · {{ .Filter }}",
+		Body:      "{{ .Text | truncate 10 | escape }}",
+		Footer:    "<a href=\"{{ .Author.URL }}\">{{ .Author.Name }}</a> · {{ .Owner.Name }}",
+		Buttons:   [][]ButtonPattern{{{Text: "Open", URL: "{{ .URL }}"}}},
+	}
+
+	err := tmpl.init()
+	if err != nil {
+		t.Fatalf("failed to init template: %v", err)
+	}
+
+	vtCli := NewVTClient("", "", 0, 1)
+	vtCli.sources = []Source{&VKSource{Name: "Cats & Dogs", OwnerID: -5}}
+	vtCli.cacheProfile(profile{ID: -5, Name: "Lost Pets", URL: "https://vk.com/lostpets"})
+	vtCli.cacheProfile(profile{ID: 7, Name: "Anna", URL: "https://vk.com/id7"})
+	route := &Route{Name: "test", Filter: "#поиск", Template: tmpl}
+
+	text, options, err := vtCli.renderPost(route, route.ChatID, &vkObject.WallWallpost{ID: 1, OwnerID: -5, SignerID: 7, Text: "<Lost> cat near the park"}, false)
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+
+	expected := "<b>Cats &amp; Dogs</b> · #поиск\n\n&lt;Lost&gt; ca…\n\n<a href=\"https://vk.com/id7\">Anna</a> · Lost Pets"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+
+	if options.ParseMode != tb.ModeHTML {
+		t.Errorf("expected HTML parse mode, got %q", options.ParseMode)
+	}
+}
+
+// TestTemplateEscape tests that escape follows the parse mode of the template.
+func TestTemplateEscape(t *testing.T) {
+	tests := []struct {
+		parseMode tb.ParseMode
+		expected  string
+	}{
+		{"", "a_b *c* [d](e) <f> 1.5!"},
+		{"html", "a_b *c* [d](e) &lt;f&gt; 1.5!"},
+		{"markdown", `a\_b \*c\* \[d](e) <f> 1.5!`},
+		{"markdownv2", `a\_b \*c\* \[d\]\(e\) <f\> 1\.5\!`},
+	}
+
+	for _, testCase := range tests {
+		t.Run(string(testCase.parseMode), func(t *testing.T) {
+			tmpl := &Template{ParseMode: testCase.parseMode, Body: "{{ .Text | escape }}"}
+
+			err := tmpl.init()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			text, _, err := tmpl.render(&postView{Text: "a_b *c* [d](e) <f> 1.5!"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if text != testCase.expected {
+				t.Errorf("expected %q, got %q", testCase.expected, text)
+			}
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package vk2tg
 
 import (
-	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,6 +20,9 @@ import (
 //nolint:mnd // just a time
 var zone = time.FixedZone("UTC+3", 3*60*60)
 
+// schedulerPeriod is how often held posts and digests are checked.
+const schedulerPeriod = time.Minute
+
 type VTClinent struct {
 	config     *config
 	tgClient   *tb.Bot
@@ -31,11 +34,32 @@ type VTClinent struct {
 	chVKPosts  chan *vkObject.WallWallpost
 	logger     *log.Logger
 	storage    storage
+	httpServer *http.Server
+	webhook    *tb.Webhook
+	routes     []*Route
+	sources    []Source
+	dedup      *Dedup
+	media      *Media
+	admins     []int64
+	election   election
+	alerts     *Alerts
+	vkReplies  *VKReplies
+	// language is the default language of the bot UI
+	language string
+	// dryRun receives what would be sent instead of Telegram
+	dryRun io.Writer
+	// simulated are fingerprints of posts a dry run would have forwarded
+	simulated []*fingerprint
+
+	// healthMu guards the error state of sources
+	healthMu sync.Mutex
+
+	// stateMu guards read-modify-write of state kept in storage
+	stateMu sync.Mutex
 }
 
 type config struct {
 	LastPostDate int           `yaml:"lastPostDate"`
-	LastPostID   int           `yaml:"lastPostId"`
 	Paused       bool          `yaml:"paused"`
 	Period       time.Duration `yaml:"period"`
 	Silent       bool          `yaml:"silent"`
@@ -43,6 +67,11 @@ type config struct {
 	TGUser       int64         `yaml:"tgUser"`
 	VKToken      string        `yaml:"vkToken"`
 
+	// HTTP server and webhook
+	HTTPListen    string `yaml:"httpListen"`
+	WebhookURL    string `yaml:"webhookUrl"`
+	WebhookSecret string `yaml:"webhookSecret"`
+
 	// Storage
 	StorageEnabled bool `yaml:"storageEnabled"`
 
@@ -64,6 +93,10 @@ func NewVTClient(tgToken, vkToken string, tgRecepient int64, period time.Duratio
 	vtcli.config.Period = period
 	vtcli.ticker = time.NewTicker(period)
 	vtcli.logger = log.New(io.Discard, "vk2tg: ", log.Ldate|log.Ltime|log.Lshortfile)
+	vtcli.storage = newMemoryStorage()
+	vtcli.election.replicaID = newReplicaID()
+	vtcli.alerts = &Alerts{After: defaultAlertAfter, Every: defaultAlertEvery}
+	vtcli.language = defaultLanguage
 
 	return vtcli
 }
@@ -77,43 +110,97 @@ func (vtCli *VTClinent) WithLogger(logger *log.Logger) *VTClinent {
 func (vtCli *VTClinent) Start() error {
 	vtCli.logger.Println("Starting...")
 
-	var err error
-
 	vtCli.vkClient = vkapi.NewVK(vtCli.config.VKToken)
 
+	// A dry run prints what would be sent, so it needs no bot at all.
+	if vtCli.dryRun == nil {
+		err := vtCli.startBot()
+		if err != nil {
+			return err
+		}
+	}
+
+	if vtCli.config.HTTPListen != "" {
+		err := vtCli.startHTTP()
+		if err != nil {
+			return err
+		}
+	}
+
+	vtCli.initRoutes()
+
+	vtCli.WG.Add(4)
+
+	go vtCli.Elector()
+	go vtCli.VKWatcher()
+	go vtCli.TGSender()
+	go vtCli.Scheduler()
+
+	return nil
+}
+
+// startBot logs in to Telegram and registers the bot commands.
+func (vtCli *VTClinent) startBot() error {
+	poller, err := vtCli.poller()
+	if err != nil {
+		return err
+	}
+
 	vtCli.tgClient, err = tb.NewBot(
 		tb.Settings{
 			Token:  vtCli.config.TGToken,
-			Poller: &tb.LongPoller{Timeout: 10 * time.Second},
+			Poller: poller,
 		},
 	)
 	if err != nil {
 		return errors.Wrap(err, "Can't longin to TG")
 	}
 
+	// Handlers that change state or publish only run on the leader.
 	vtCli.tgClient.Handle("/status", vtCli.status)
-	vtCli.tgClient.Handle("/pause", vtCli.pause)
-	vtCli.tgClient.Handle("/mute", vtCli.mute)
-
-	err = vtCli.tgClient.SetCommands(
-		[]tb.Command{
-			{Text: "mute", Description: "(Un)mute bot"},
-			{Text: "pause", Description: "(Un)pause bot"},
-			{Text: "status", Description: "Show current status"},
-		},
-	)
+	vtCli.tgClient.Handle("/pause", vtCli.pause, vtCli.leading)
+	vtCli.tgClient.Handle("/mute", vtCli.mute, vtCli.leading)
+	vtCli.tgClient.Handle("/history", vtCli.history)
+	vtCli.tgClient.Handle("/resend", vtCli.resend, vtCli.leading)
+	vtCli.tgClient.Handle("/search", vtCli.search)
+	vtCli.tgClient.Handle("/stats", vtCli.statsCommand)
+	vtCli.tgClient.Handle("/comment", vtCli.comment)
+	vtCli.tgClient.Handle("/language", vtCli.setLanguage)
+	vtCli.tgClient.Handle(&tb.InlineButton{Unique: approveUnique}, vtCli.moderationApprove, vtCli.leading)
+	vtCli.tgClient.Handle(&tb.InlineButton{Unique: rejectUnique}, vtCli.moderationReject, vtCli.leading)
+	vtCli.tgClient.Handle(&tb.InlineButton{Unique: editUnique}, vtCli.moderationEdit, vtCli.leading)
+	vtCli.tgClient.Handle(&tb.InlineButton{Unique: handledUnique}, vtCli.markHandled, vtCli.leading)
+	vtCli.tgClient.Handle(&tb.InlineButton{Unique: lessUnique}, vtCli.lessLikeThis, vtCli.leading)
+	vtCli.tgClient.Handle(tb.OnText, vtCli.onText, vtCli.leading)
+
+	err = vtCli.setCommands()
 	if err != nil {
-		return errors.Wrap(err, "can't set commands")
+		return err
 	}
 
-	go vtCli.tgClient.Start()
+	// With long polling the bot is started by the elected leader only.
+	if vtCli.webhook != nil {
+		go vtCli.tgClient.Start()
+	}
 
-	vtCli.WG.Add(2)
+	return nil
+}
 
-	go vtCli.VKWatcher()
-	go vtCli.TGSender()
+// initRoutes falls back to the default source and route and sends routes without a chat to the configured user.
+func (vtCli *VTClinent) initRoutes() {
+	if len(vtCli.sources) == 0 {
+		vtCli.sources = defaultSources()
+	}
 
-	return nil
+	if len(vtCli.routes) == 0 {
+		vtCli.routes = []*Route{vtCli.defaultRoute()}
+	}
+
+	for _, route := range vtCli.routes {
+		if route.ChatID == 0 && len(route.sinks) == 0 {
+			route.ChatID = vtCli.config.TGUser
+		}
+	}
 }
 
 func (vtCli *VTClinent) Pause() {
@@ -136,6 +223,18 @@ func (vtCli *VTClinent) Unmute() {
 	vtCli.config.Silent = false
 }
 
+// loadLastPosts restores the position of every source from storage.
+func (vtCli *VTClinent) loadLastPosts() error {
+	for _, source := range vtCli.sources {
+		err := source.load(vtCli.storage)
+		if err != nil {
+			return errors.Wrapf(err, "source %s", source.name())
+		}
+	}
+
+	return nil
+}
+
 func (vtCli *VTClinent) Wait() {
 	vtCli.WG.Wait()
 }
@@ -145,115 +244,360 @@ func (vtCli *VTClinent) VKWatcher() {
 	defer vtCli.WG.Done()
 
 	for range vtCli.ticker.C {
+		if !vtCli.isLeader() {
+			continue
+		}
+
 		vtCli.LastUpdate = time.Now()
 
-		vkWall, err := vtCli.vkClient.WallGet(
-			vkapi.Params{
-				"owner_id": -57692133,
-				"count":    10,
-			},
-		)
-		if err != nil {
-			vtCli.logger.Printf("failed to fetch posts: %s", err)
+		for _, source := range vtCli.sources {
+			if vtCli.waiting(source) {
+				continue
+			}
 
-			continue
+			vtCli.recordFetch(source, vtCli.fetchSource(source))
 		}
+	}
+}
+
+func (vtCli *VTClinent) TGSender() {
+	vtCli.WG.Add(1)
+	defer vtCli.WG.Done()
+	defer vtCli.logger.Println("Sender: done")
+
+	for post := range vtCli.chVKPosts {
+		vtCli.process(post)
+	}
+}
+
+// process drops near-duplicates and hands the post to every matching route.
+func (vtCli *VTClinent) process(post *vkObject.WallWallpost) {
+	if vtCli.dryRun != nil {
+		vtCli.simulate(post)
+
+		return
+	}
+
+	source := vtCli.sourceName(post.OwnerID)
+	vtCli.count(statsSources, source, counterPosts, 1)
+
+	var entry *fingerprint
+
+	if vtCli.dedup != nil {
+		entry = vtCli.fingerprint(post)
+
+		if vtCli.checkDuplicate(entry) {
+			vtCli.count(statsSources, source, counterDuplicates, 1)
+
+			return
+		}
+	}
+
+	if author := vtCli.postAuthor(post); author.ID != 0 {
+		vtCli.logger.Printf("Post %d: By %s (%s) on %s", post.ID, author.Name, author.URL, vtCli.resolveName(post.OwnerID).Name)
+	}
+
+	var (
+		deliveries []sentMessage
+		matched    bool
+	)
+
+	for _, route := range vtCli.routes {
+		if !route.Match(post) {
+			vtCli.logger.Printf("Post %d: Does not match route %s, skipping", post.ID, route.Name)
 
-		if vkWall.Items[0].ID == vtCli.config.LastPostID {
 			continue
 		}
 
-		for index := vkWall.Count - 1; index >= 0; index-- {
-			vtCli.logger.Printf("Post %d: Processing", vkWall.Items[index].ID)
+		matched = true
 
-			if vtCli.config.LastPostID >= vkWall.Items[index].ID {
-				vtCli.logger.Printf("Post %d: Not a new post, skipped", vkWall.Items[index].ID)
+		vtCli.count(statsRoutes, route.Name, counterMatched, 1)
+		vtCli.count(statsFilters, filterName(route), counterMatched, 1)
 
-				continue
-			}
+		deliveries = append(deliveries, vtCli.dispatch(route, post)...)
+	}
 
-			vtCli.logger.Printf("Post %d: Selected as latest", vkWall.Items[index].ID)
-			vtCli.config.LastPostDate = vkWall.Items[index].Date
-			vtCli.config.LastPostID = vkWall.Items[index].ID
+	if matched {
+		vtCli.count(statsSources, source, counterMatched, 1)
+	}
 
-			if vtCli.config.StorageEnabled {
-				vtCli.storage.SetLastPost(vkWall.Items[index].ID)
-			}
+	if len(deliveries) > 0 {
+		vtCli.archivePost(post, deliveries)
+	}
 
-			if !strings.Contains(vkWall.Items[index].Text, "#поиск") {
-				vtCli.logger.Printf("Post %d: Post does not contain required substring, skipping", vkWall.Items[index].ID)
+	// A post no route took isn't remembered, so an edited repost that matches isn't a duplicate of it.
+	if entry != nil && matched {
+		entry.Messages = deliveries
+		vtCli.rememberPost(entry)
+	}
+}
 
-				continue
+// dispatch submits the post for moderation if the route requires it and publishes it otherwise.
+// It returns where the post was delivered.
+func (vtCli *VTClinent) dispatch(route *Route, post *vkObject.WallWallpost) []sentMessage {
+	if route.Moderation != nil {
+		err := vtCli.submitForModeration(route, post)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't submit for moderation in route %s: %s", post.ID, route.Name, err)
+
+			return nil
+		}
+
+		vtCli.logger.Printf("Post %d: Submitted for moderation in route %s", post.ID, route.Name)
+
+		return nil
+	}
+
+	deliveries, _ := vtCli.publish(route, post, nil)
+
+	return deliveries
+}
+
+// publish sends the post right away, adds it to the route digest,
+// holds it until the route's quiet hours are over or queues it by the route schedule.
+// It returns where the post was sent, targets in done are skipped as already delivered.
+// Failures are logged, the error tells callers that keep the post to try again.
+func (vtCli *VTClinent) publish(route *Route, post *vkObject.WallWallpost, done []sentMessage) ([]sentMessage, error) {
+	if route.Digest != nil {
+		err := vtCli.addToDigest(route, post)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't add to digest of route %s: %s", post.ID, route.Name, err)
+
+			return nil, err
+		}
+
+		vtCli.logger.Printf("Post %d: Added to digest of route %s", post.ID, route.Name)
+
+		return nil, nil
+	}
+
+	silent := vtCli.config.Silent
+
+	if route.QuietHours != nil {
+		if until, quiet := route.QuietHours.ActiveUntil(time.Now()); quiet {
+			if route.QuietHours.Mode == QuietModeHold {
+				err := vtCli.holdPost(route, post)
+				if err != nil {
+					vtCli.logger.Printf("Post %d: Can't hold for route %s: %s", post.ID, route.Name, err)
+
+					return nil, err
+				}
+
+				vtCli.logger.Printf("Post %d: Held by route %s until %s", post.ID, route.Name, until.Format(time.RFC822))
+
+				return nil, nil
 			}
 
-			vtCli.logger.Printf("Post %d: Sending to TG", vkWall.Items[index].ID)
+			silent = true
+		}
+	}
+
+	if route.Schedule != nil {
+		due, err := vtCli.enqueue(route, post)
+		if err != nil {
+			vtCli.logger.Printf("Post %d: Can't queue for route %s: %s", post.ID, route.Name, err)
 
-			vtCli.chVKPosts <- &vkWall.Items[index]
+			return nil, err
 		}
+
+		vtCli.logger.Printf("Post %d: Queued by route %s, due %s", post.ID, route.Name, due.In(zone).Format(time.RFC822))
+
+		return nil, nil
 	}
+
+	return vtCli.send(route, post, silent, done)
 }
 
-func (vtCli *VTClinent) TGSender() {
+// send delivers the post to the route sinks and chat at once and returns where it was delivered.
+// Targets in done are skipped, the error reports the targets that failed.
+func (vtCli *VTClinent) send(
+	route *Route, post *vkObject.WallWallpost, silent bool, done []sentMessage,
+) ([]sentMessage, error) {
+	var (
+		sinkDeliveries []sentMessage
+		sinkErr        error
+		wg             sync.WaitGroup
+	)
+
+	wg.Go(func() { sinkDeliveries, sinkErr = vtCli.sendToSinks(route, post, silent, done) })
+
+	deliveries, err := vtCli.sendToChat(route, post, silent, done)
+
+	wg.Wait()
+
+	return append(deliveries, sinkDeliveries...), errors.CombineErrors(err, sinkErr)
+}
+
+// sendToChat delivers the post to the route chat unless it is done or muted by the subscriber.
+func (vtCli *VTClinent) sendToChat(
+	route *Route, post *vkObject.WallWallpost, silent bool, done []sentMessage,
+) ([]sentMessage, error) {
+	// Routes with sinks only have no chat of their own.
+	if route.ChatID == 0 || delivered(done, route.Name, "") {
+		return nil, nil
+	}
+
+	if keyword := vtCli.mutedKeyword(route.ChatID, post); keyword != "" {
+		vtCli.logger.Printf("Post %d: Muted %s by subscriber of route %s, skipping", post.ID, keyword, route.Name)
+
+		return nil, nil
+	}
+
+	msg, err := vtCli.deliver(route, post, silent)
+	vtCli.countDelivery(route, err)
+
+	if err != nil {
+		vtCli.logger.Printf("Post %d: Can't deliver via route %s: %s", post.ID, route.Name, err)
+
+		return nil, err
+	}
+
+	vtCli.logger.Printf("Post %d: Sent via route %s", post.ID, route.Name)
+
+	// Feed items have no VK comments to follow.
+	if route.Comments != nil && !isFeedOwner(post.OwnerID) {
+		vtCli.followComments(route, post, msg)
+	}
+
+	return []sentMessage{{Route: route.Name, ChatID: msg.Chat.ID, MessageID: msg.ID}}, nil
+}
+
+// Scheduler periodically handles delayed work: posts held by quiet hours,
+// queued by route schedules, pending digests and comments of followed posts.
+func (vtCli *VTClinent) Scheduler() {
 	vtCli.WG.Add(1)
 	defer vtCli.WG.Done()
-	defer vtCli.logger.Println("Sender: done")
 
-	for post := range vtCli.chVKPosts {
-		var (
-			album tb.Album
-			url   *string
-		)
-
-		for attachmentsIndex := range post.Attachments {
-			if post.Attachments[attachmentsIndex].Type == "photo" {
-				var maxSize float64
-
-				for sizeIndex := range post.Attachments[attachmentsIndex].Photo.Sizes {
-					//nolint:lll // whis can't be shorter
-					if maxSize < post.Attachments[attachmentsIndex].Photo.Sizes[sizeIndex].Width*post.Attachments[attachmentsIndex].Photo.Sizes[sizeIndex].Height {
-						maxSize = post.Attachments[attachmentsIndex].Photo.Sizes[sizeIndex].Width *
-							post.Attachments[attachmentsIndex].Photo.Sizes[sizeIndex].Height
-						url = &post.Attachments[attachmentsIndex].Photo.Sizes[sizeIndex].URL
-					}
-				}
+	ticker := time.NewTicker(schedulerPeriod)
+	defer ticker.Stop()
 
-				album = append(album, &tb.Photo{
-					File: tb.FromURL(*url),
-				})
-			}
+	for range ticker.C {
+		if !vtCli.isLeader() {
+			continue
+		}
+
+		vtCli.releaseHeld()
+		vtCli.releaseQueued()
+		vtCli.sendDigests()
+		vtCli.pollComments()
+	}
+}
+
+// releaseHeld delivers held posts once the quiet hours of their route are over.
+func (vtCli *VTClinent) releaseHeld() {
+	for _, route := range vtCli.routes {
+		if route.QuietHours == nil {
+			continue
 		}
 
-		if len(album) > 0 {
-			_, err := vtCli.tgClient.SendAlbum(&tb.User{ID: vtCli.config.TGUser}, album)
+		if _, quiet := route.QuietHours.ActiveUntil(time.Now()); quiet {
+			continue
+		}
+
+		for {
+			item := vtCli.takePost(route, heldKey(route), nil)
+			if item == nil {
+				break
+			}
+
+			deliveries, err := vtCli.publish(route, item.Post, item.Delivered)
+			vtCli.finishPost(route, heldKey(route), item, deliveries, err)
+
 			if err != nil {
-				vtCli.logger.Printf("Can't send album: %s\n", err)
+				break
 			}
 		}
+	}
+}
 
-		_, err := vtCli.tgClient.Send(
-			&tb.User{ID: vtCli.config.TGUser},
-			post.Text,
-			vtCli.generateOptionsForPost(post),
-		)
-		if err != nil {
-			vtCli.logger.Printf("Can't send message: %s\n", err)
+// deliver sends the post photos as an album followed by the post text.
+func (vtCli *VTClinent) deliver(route *Route, post *vkObject.WallWallpost, silent bool) (*tb.Message, error) {
+	return vtCli.deliverTo(route, route.ChatID, post, silent)
+}
 
-			return
+// deliverTo sends the post to the chat the way the route renders it.
+func (vtCli *VTClinent) deliverTo(route *Route, chatID int64, post *vkObject.WallWallpost, silent bool) (*tb.Message, error) {
+	return vtCli.deliverWith(route, route.Template, chatID, post, silent)
+}
+
+// deliverWith sends the post of the route to the chat rendered with the template.
+func (vtCli *VTClinent) deliverWith(
+	route *Route, tmpl *Template, chatID int64, post *vkObject.WallWallpost, silent bool,
+) (*tb.Message, error) {
+	recipient := tb.ChatID(chatID)
+
+	text, options, err := vtCli.renderPostWith(route, tmpl, chatID, post, silent)
+	if err != nil {
+		return nil, err
+	}
+
+	vtCli.sendPhotos(route, recipient, post, silent)
+
+	msg, err := vtCli.tgClient.Send(recipient, text, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't send message")
+	}
+
+	if route.Geo != nil && route.Geo.Send {
+		vtCli.sendGeo(post, msg, silent)
+	}
+
+	return msg, nil
+}
+
+// sendPhotos sends photos of the post as an album, transformed with the route image settings.
+func (vtCli *VTClinent) sendPhotos(route *Route, recipient tb.Recipient, post *vkObject.WallWallpost, silent bool) {
+	var (
+		album tb.Album
+		files []*mediaFile
+	)
+
+	defer func() {
+		for _, file := range files {
+			file.release()
 		}
+	}()
 
-		chat, err := vtCli.tgClient.ChatByID(vtCli.config.TGUser)
-		if err != nil {
-			vtCli.logger.Printf("Error on fetching user info: %s", err)
+	for attachmentsIndex := range post.Attachments {
+		if post.Attachments[attachmentsIndex].Type != "photo" {
+			continue
+		}
 
-			return
+		photo := &post.Attachments[attachmentsIndex].Photo
+
+		url := photoURL(photo)
+		if url == "" {
+			continue
 		}
 
-		vtCli.logger.Printf("Post %d: Sent to %s", post.ID, chat.FirstName)
+		file := vtCli.mediaFile(photoKey(photo), url, route.Images)
+		if file == nil {
+			continue
+		}
+		files = append(files, file)
+		album = append(album, &tb.Photo{File: file.file})
+	}
+
+	if len(album) == 0 {
+		return
+	}
+
+	msgs, err := vtCli.tgClient.SendAlbum(recipient, album, &tb.SendOptions{DisableNotification: silent})
+	if err != nil {
+		vtCli.logger.Printf("Can't send album: %s\n", err)
+
+		return
+	}
+
+	for index := range msgs {
+		if index < len(files) {
+			vtCli.rememberFile(files[index], &msgs[index])
+		}
 	}
 }
 
-func (vtCli *VTClinent) sendMessage(u *tb.User, options ...any) error {
-	_, err := vtCli.tgClient.Send(u, options)
+func (vtCli *VTClinent) sendMessage(u *tb.User, what any, options ...any) error {
+	_, err := vtCli.tgClient.Send(u, what, options...)
 	if err != nil {
 		return errors.Wrap(err, "error on sending message")
 	}
@@ -262,13 +606,41 @@ func (vtCli *VTClinent) sendMessage(u *tb.User, options ...any) error {
 }
 
 func (vtCli *VTClinent) status(tbContext tb.Context) error {
-	msg := fmt.Sprintf("I'm fine\nLast post date:\t%s\nReceived in:\t%s\nUptime:\t%s\nPaused:\t%t\nSound:\t%t",
-		time.Unix(int64(vtCli.config.LastPostDate), 0).In(zone).Format(time.RFC822),
-		vtCli.LastUpdate.In(zone).Format(time.RFC822),
-		time.Since(vtCli.StartTime).Round(time.Second),
-		vtCli.config.Paused,
-		!vtCli.config.Silent,
-	)
+	lang := vtCli.userLanguage(tbContext)
+
+	lines := []string{
+		translate(lang, "status.header"),
+		translate(lang, "status.lastPost", time.Unix(int64(vtCli.config.LastPostDate), 0).In(zone).Format(time.RFC822)),
+		translate(lang, "status.received", vtCli.LastUpdate.In(zone).Format(time.RFC822)),
+		translate(lang, "status.uptime", time.Since(vtCli.StartTime).Round(time.Second)),
+		translate(lang, "status.paused", yesNo(lang, vtCli.config.Paused)),
+		translate(lang, "status.sound", yesNo(lang, !vtCli.config.Silent)),
+	}
+
+	lines = append(lines, vtCli.healthStatus(lang)...)
+	lines = append(lines, vtCli.leaderStatus(lang))
+
+	for _, route := range vtCli.routes {
+		if route.QuietHours == nil {
+			continue
+		}
+
+		line := translate(lang, "status.quietHours", route.Name, route.QuietHours)
+
+		if until, quiet := route.QuietHours.ActiveUntil(time.Now()); quiet {
+			line += translate(lang, "status.quietActive", until.Format("15:04"))
+		}
+
+		lines = append(lines, line)
+	}
+
+	for _, route := range vtCli.routes {
+		if route.Schedule != nil {
+			lines = append(lines, translate(lang, "status.queue", route.Name, vtCli.queueStatus(route, lang)))
+		}
+	}
+
+	msg := strings.Join(lines, "\n")
 
 	_, err := vtCli.tgClient.Send(tbContext.Sender(), msg)
 	if err != nil {
@@ -282,14 +654,14 @@ func (vtCli *VTClinent) pause(tbContext tb.Context) error {
 	if !vtCli.config.Paused {
 		vtCli.Pause()
 
-		err := vtCli.sendMessage(tbContext.Sender(), "Paused! Send /pause to continue")
+		err := vtCli.sendMessage(tbContext.Sender(), vtCli.tr(tbContext, "pause.on"))
 		if err != nil {
 			vtCli.logger.Println(err)
 		}
 	} else {
 		vtCli.Resume()
 
-		err := vtCli.sendMessage(tbContext.Sender(), "Unpaused! Send /pause to stop")
+		err := vtCli.sendMessage(tbContext.Sender(), vtCli.tr(tbContext, "pause.off"))
 		if err != nil {
 			vtCli.logger.Println(err)
 		}
@@ -302,14 +674,14 @@ func (vtCli *VTClinent) mute(tbContext tb.Context) error {
 	if !vtCli.config.Silent {
 		vtCli.Mute()
 
-		err := vtCli.sendMessage(tbContext.Sender(), "Muted! Send /mute to go loud")
+		err := vtCli.sendMessage(tbContext.Sender(), vtCli.tr(tbContext, "mute.on"))
 		if err != nil {
 			vtCli.logger.Println(err)
 		}
 	} else {
 		vtCli.Unmute()
 
-		err := vtCli.sendMessage(tbContext.Sender(), "Unmuted! Send /mute to go silent")
+		err := vtCli.sendMessage(tbContext.Sender(), vtCli.tr(tbContext, "mute.off"))
 		if err != nil {
 			vtCli.logger.Println(err)
 		}
@@ -318,23 +690,39 @@ func (vtCli *VTClinent) mute(tbContext tb.Context) error {
 	return nil
 }
 
-func (vtCli *VTClinent) generateOptionsForPost(post *vkObject.WallWallpost) *tb.SendOptions {
-	return &tb.SendOptions{
-		ReplyTo: &tb.Message{},
-		ReplyMarkup: &tb.ReplyMarkup{
-			InlineKeyboard: [][]tb.InlineButton{
-				{
-					tb.InlineButton{
-						Text: "🌎 К посту",
-						URL:  "https://vk.com/wall-57692133_" + strconv.Itoa(post.ID),
-					},
-					tb.InlineButton{
-						Text: "✍️ Написать",
-						URL:  "vk.com/write" + strconv.Itoa(post.SignerID),
-					},
-				},
-			},
-		},
-		DisableNotification: vtCli.config.Silent,
+// postURL returns the link to the post on VK or, for feed items, to the original article.
+func postURL(post *vkObject.WallWallpost) string {
+	if isFeedOwner(post.OwnerID) {
+		return post.Copyright.Link
 	}
+
+	return "https://vk.com/wall" + strconv.Itoa(post.OwnerID) + "_" + strconv.Itoa(post.ID)
+}
+
+// photoURL returns the URL of the largest size of the photo.
+func photoURL(photo *vkObject.PhotosPhoto) string {
+	var (
+		maxSize float64
+		url     string
+	)
+
+	for sizeIndex := range photo.Sizes {
+		if size := photo.Sizes[sizeIndex].Width * photo.Sizes[sizeIndex].Height; maxSize < size {
+			maxSize = size
+			url = photo.Sizes[sizeIndex].URL
+		}
+	}
+
+	return url
+}
+
+// firstPhoto returns the first photo attached to the post, nil if there are none.
+func firstPhoto(post *vkObject.WallWallpost) *vkObject.PhotosPhoto {
+	for index := range post.Attachments {
+		if post.Attachments[index].Type == "photo" && photoURL(&post.Attachments[index].Photo) != "" {
+			return &post.Attachments[index].Photo
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,237 @@
+package vk2tg
+
+import (
+	"time"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	"github.com/cockroachdb/errors"
+	tb "gopkg.in/telebot.v4"
+)
+
+const (
+	// defaultAlertAfter is the number of consecutive failures before admins are alerted.
+	defaultAlertAfter = 3
+	// defaultAlertEvery is the minimal interval between repeated alerts about the same source.
+	defaultAlertEvery = time.Hour
+	// maxBackoff caps the pause between retries of a failing source.
+	maxBackoff = 30 * time.Minute
+)
+
+// vkErrorClass groups VK API errors by how the watcher reacts to them.
+type vkErrorClass int
+
+const (
+	vkErrorOther vkErrorClass = iota
+	vkErrorAuth
+	vkErrorTooMany
+	vkErrorAccess
+	vkErrorCaptcha
+)
+
+func (class vkErrorClass) String() string {
+	return class.describe().in(langEN)
+}
+
+// describe returns the catalog text of the class.
+func (class vkErrorClass) describe() localized {
+	switch class {
+	case vkErrorAuth:
+		return localized{"vkerror.auth", nil}
+	case vkErrorTooMany:
+		return localized{"vkerror.tooMany", nil}
+	case vkErrorAccess:
+		return localized{"vkerror.access", nil}
+	case vkErrorCaptcha:
+		return localized{"vkerror.captcha", nil}
+	case vkErrorOther:
+	}
+
+	return localized{"vkerror.other", nil}
+}
+
+// backoff is the first pause after an error of the class, doubled with every next failure.
+func (class vkErrorClass) backoff() time.Duration {
+	switch class {
+	case vkErrorAuth, vkErrorAccess:
+		// Needs a human, no point in asking often.
+		return 10 * time.Minute //nolint:mnd // see above
+	case vkErrorTooMany, vkErrorCaptcha:
+		return time.Minute
+	case vkErrorOther:
+	}
+
+	return 0
+}
+
+// Alerts configures admin notifications about failing sources.
+type Alerts struct {
+	After int           `yaml:"after"`
+	Every time.Duration `yaml:"every"`
+}
+
+func (alerts *Alerts) init() error {
+	if alerts.After == 0 {
+		alerts.After = defaultAlertAfter
+	}
+
+	if alerts.Every == 0 {
+		alerts.Every = defaultAlertEvery
+	}
+
+	if alerts.After < 0 || alerts.Every < 0 {
+		return errors.New("after and every must be positive")
+	}
+
+	return nil
+}
+
+// sourceHealth is the error state of a source.
+type sourceHealth struct {
+	failures  int
+	class     vkErrorClass
+	lastErr   error
+	since     time.Time
+	retryAt   time.Time
+	lastAlert time.Time
+}
+
+func classifyVKError(err error) vkErrorClass {
+	switch {
+	case errors.Is(err, vkapi.ErrAuth):
+		return vkErrorAuth
+	case errors.Is(err, vkapi.ErrTooMany), errors.Is(err, vkapi.ErrFlood), errors.Is(err, vkapi.ErrRateLimit):
+		return vkErrorTooMany
+	case errors.Is(err, vkapi.ErrCaptcha):
+		return vkErrorCaptcha
+	case errors.Is(err, vkapi.ErrAccess), errors.Is(err, vkapi.ErrPermission), errors.Is(err, vkapi.ErrAccessGroup),
+		errors.Is(err, vkapi.ErrPrivateProfile), errors.Is(err, vkapi.ErrBlocked), errors.Is(err, vkapi.ErrUserDeleted):
+		return vkErrorAccess
+	}
+
+	return vkErrorOther
+}
+
+// retryDelay is how long a source rests after its failures-th consecutive error.
+func retryDelay(class vkErrorClass, failures int, period time.Duration) time.Duration {
+	delay := max(class.backoff(), period)
+
+	for range failures - 1 {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return delay
+}
+
+// waiting reports whether the source is resting after an error.
+func (vtCli *VTClinent) waiting(source Source) bool {
+	vtCli.healthMu.Lock()
+	defer vtCli.healthMu.Unlock()
+
+	return time.Now().Before(source.state().retryAt)
+}
+
+// recordFetch updates the error state of the source, alerting admins when it keeps failing and when it heals.
+func (vtCli *VTClinent) recordFetch(source Source, err error) {
+	if alert := vtCli.updateHealth(source, err); alert.key != "" {
+		vtCli.notifyAdmins(alert)
+	}
+}
+
+// updateHealth updates the error state of the source and returns an alert for admins if one is due.
+func (vtCli *VTClinent) updateHealth(source Source, err error) localized {
+	vtCli.healthMu.Lock()
+	defer vtCli.healthMu.Unlock()
+
+	health := source.state()
+
+	if err == nil {
+		var alert localized
+
+		if health.failures > 0 {
+			vtCli.logger.Printf("Source %s: Recovered after %d failures", source.name(), health.failures)
+
+			if !health.lastAlert.IsZero() {
+				alert = localized{"alert.recovered", []any{
+					source.name(), health.failures, health.since.In(zone).Format(time.RFC822),
+				}}
+			}
+		}
+
+		*health = sourceHealth{}
+
+		return alert
+	}
+
+	if health.failures == 0 {
+		health.since = time.Now()
+	}
+
+	health.failures++
+	health.class = classifyVKError(err)
+	health.lastErr = err
+
+	delay := retryDelay(health.class, health.failures, vtCli.config.Period)
+	health.retryAt = time.Now().Add(delay)
+
+	vtCli.logger.Printf("Source %s: %s, retry in %s: %s", source.name(), health.class, delay, err)
+
+	if health.failures < vtCli.alerts.After || time.Since(health.lastAlert) < vtCli.alerts.Every {
+		return localized{}
+	}
+
+	health.lastAlert = time.Now()
+
+	return localized{"alert.failing", []any{
+		source.name(), health.class.describe(), health.failures, health.since.In(zone).Format(time.RFC822), delay, err,
+	}}
+}
+
+// notifyAdmins sends the text to the bot owner and every admin, each in the language of their chat.
+func (vtCli *VTClinent) notifyAdmins(text localized) {
+	if vtCli.dryRun != nil {
+		vtCli.logger.Printf("Dry run: Not alerting admins: %s", text.in(langEN))
+
+		return
+	}
+
+	recipients := append([]int64{vtCli.config.TGUser}, vtCli.admins...)
+
+	for _, admin := range recipients {
+		if admin == 0 {
+			continue
+		}
+
+		_, err := vtCli.tgClient.Send(tb.ChatID(admin), text.in(vtCli.chatLanguage(admin)))
+		if err != nil {
+			vtCli.logger.Printf("Can't alert admin %d: %s", admin, err)
+		}
+	}
+}
+
+// healthStatus describes failing sources for /status, a line each.
+func (vtCli *VTClinent) healthStatus(lang string) []string {
+	vtCli.healthMu.Lock()
+	defer vtCli.healthMu.Unlock()
+
+	var status []string
+
+	for _, source := range vtCli.sources {
+		health := source.state()
+		if health.failures == 0 {
+			continue
+		}
+
+		status = append(status, translate(lang, "status.source",
+			source.name(), health.class.describe().in(lang), health.failures,
+			health.since.In(zone).Format(time.RFC822), health.retryAt.In(zone).Format("15:04:05")))
+	}
+
+	if len(status) == 0 {
+		return []string{translate(lang, "status.sourcesOK")}
+	}
+
+	return status
+}
@@ -0,0 +1,80 @@
+package vk2tg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	vkapi "github.com/SevereCloud/vksdk/v3/api"
+	"github.com/cockroachdb/errors"
+)
+
+// TestClassifyVKError tests that wrapped VK API errors are recognized.
+func TestClassifyVKError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected vkErrorClass
+	}{
+		{"auth", &vkapi.Error{Code: vkapi.ErrAuth}, vkErrorAuth},
+		{"too many", errors.Wrap(&vkapi.Error{Code: vkapi.ErrTooMany}, "failed to fetch posts"), vkErrorTooMany},
+		{"captcha", errors.Wrap(&vkapi.Error{Code: vkapi.ErrCaptcha}, "failed to fetch posts"), vkErrorCaptcha},
+		{"access", errors.Wrap(&vkapi.Error{Code: vkapi.ErrAccess}, "failed to fetch posts"), vkErrorAccess},
+		{"network", errors.New("connection refused"), vkErrorOther},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if class := classifyVKError(testCase.err); class != testCase.expected {
+				t.Errorf("expected %s, got %s", testCase.expected, class)
+			}
+		})
+	}
+}
+
+// TestRetryDelay tests the exponential backoff and its cap.
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		class    vkErrorClass
+		failures int
+		expected time.Duration
+	}{
+		{"first network error waits a period", vkErrorOther, 1, 10 * time.Second},
+		{"network errors double", vkErrorOther, 3, 40 * time.Second},
+		{"rate limit starts at a minute", vkErrorTooMany, 1, time.Minute},
+		{"auth doubles", vkErrorAuth, 2, 20 * time.Minute},
+		{"capped", vkErrorAuth, 10, maxBackoff},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			if delay := retryDelay(testCase.class, testCase.failures, 10*time.Second); delay != testCase.expected {
+				t.Errorf("expected %s, got %s", testCase.expected, delay)
+			}
+		})
+	}
+}
+
+// TestFailingAlertLanguage tests that the error class is described in the language of the alert.
+func TestFailingAlertLanguage(t *testing.T) {
+	alert := localized{"alert.failing", []any{
+		"club1", vkErrorTooMany.describe(), 3, "01 Jan 26 10:00 MSK", time.Minute, errors.New("too many requests"),
+	}}
+
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{langEN, "Source club1: rate limited\n"},
+		{langRU, "Источник club1: слишком много запросов\n"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.lang, func(t *testing.T) {
+			if text := alert.in(testCase.lang); !strings.HasPrefix(text, "⚠️ "+testCase.expected) {
+				t.Errorf("expected %q, got %q", testCase.expected, text)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package vk2tg
+
+import (
+	"context"
+	"html"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// discordDescriptionLimit is the max length of a Discord embed description.
+	discordDescriptionLimit = 4096
+	// discordTitleLimit is the max length of a Discord embed title.
+	discordTitleLimit = 256
+)
+
+// WebhookSink posts every forwarded post as JSON to an URL.
+// Retries carry the same Idempotency-Key header, so the receiver can drop repeats.
+type WebhookSink struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type webhookPayload struct {
+	Route   string          `json:"route"`
+	Source  string          `json:"source"`
+	OwnerID int             `json:"ownerId"`
+	PostID  int             `json:"postId"`
+	URL     string          `json:"url"`
+	Text    string          `json:"text"`
+	Date    time.Time       `json:"date"`
+	Author  *webhookProfile `json:"author,omitempty"`
+	Photos  []string        `json:"photos,omitempty"`
+}
+
+type webhookProfile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func validateURL(name, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.Newf("%s: a http(s) URL is required", name)
+	}
+
+	return nil
+}
+
+func (sink *WebhookSink) init() error {
+	return validateURL("webhook", sink.URL)
+}
+
+func (sink *WebhookSink) Send(ctx context.Context, message *outgoing) error {
+	payload := webhookPayload{
+		Route:   message.route.Name,
+		Source:  message.view.Source,
+		OwnerID: message.post.OwnerID,
+		PostID:  message.post.ID,
+		URL:     message.view.URL,
+		Text:    message.text,
+		Date:    message.view.Date,
+		Photos:  message.photos,
+	}
+
+	if message.view.Author.ID != 0 {
+		payload.Author = &webhookProfile{
+			ID:   message.view.Author.ID,
+			Name: message.view.Author.Name,
+			URL:  message.view.Author.URL,
+		}
+	}
+
+	headers := map[string]string{"Idempotency-Key": idempotencyKey(message)}
+	maps.Copy(headers, sink.Headers)
+
+	return sendJSON(ctx, http.MethodPost, sink.URL, headers, payload)
+}
+
+func (sink *WebhookSink) idempotent() {}
+
+// DiscordSink posts an embed with the post to a Discord channel webhook.
+type DiscordSink struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+}
+
+type discordPayload struct {
+	Username string         `json:"username,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Author      *discordAuthor `json:"author,omitempty"`
+	Image       *discordImage  `json:"image,omitempty"`
+}
+
+type discordAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type discordImage struct {
+	URL string `json:"url"`
+}
+
+func (sink *DiscordSink) init() error {
+	return validateURL("discord", sink.URL)
+}
+
+func (sink *DiscordSink) Send(ctx context.Context, message *outgoing) error {
+	embed := discordEmbed{
+		Title:       truncate(discordTitleLimit, message.view.Source),
+		URL:         message.view.URL,
+		Description: truncate(discordDescriptionLimit, message.text),
+		Timestamp:   message.view.Date.Format(time.RFC3339),
+	}
+
+	if message.view.Author.ID != 0 {
+		embed.Author = &discordAuthor{Name: message.view.Author.Name, URL: message.view.Author.URL}
+	}
+
+	if len(message.photos) > 0 {
+		embed.Image = &discordImage{URL: message.photos[0]}
+	}
+
+	// Wait for the message to be created, so errors are reported.
+	return sendJSON(ctx, http.MethodPost, sink.URL+"?wait=true", nil, discordPayload{
+		Username: sink.Username,
+		Embeds:   []discordEmbed{embed},
+	})
+}
+
+// MatrixSink sends posts to a Matrix room through the client-server API.
+type MatrixSink struct {
+	Homeserver string `yaml:"homeserver"`
+	RoomID     string `yaml:"roomId"`
+	Token      string `yaml:"token"`
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+func (sink *MatrixSink) init() error {
+	if sink.RoomID == "" || sink.Token == "" {
+		return errors.New("matrix: room ID and token are required")
+	}
+
+	return validateURL("matrix", sink.Homeserver)
+}
+
+func (sink *MatrixSink) Send(ctx context.Context, message *outgoing) error {
+	links := append([]string{message.view.URL}, message.photos...)
+
+	plain := message.text + "\n\n" + strings.Join(links, "\n")
+
+	var formatted strings.Builder
+
+	formatted.WriteString(strings.ReplaceAll(html.EscapeString(message.text), "\n", "<br>"))
+
+	for _, link := range links {
+		escaped := html.EscapeString(link)
+		formatted.WriteString(`<br><a href="` + escaped + `">` + escaped + `</a>`)
+	}
+
+	// The transaction ID is stable for the post, so a retried request isn't posted twice.
+	endpoint := strings.TrimSuffix(sink.Homeserver, "/") + "/_matrix/client/v3/rooms/" +
+		url.PathEscape(sink.RoomID) + "/send/m.room.message/" + url.PathEscape(idempotencyKey(message))
+
+	return sendJSON(ctx, http.MethodPut, endpoint, map[string]string{"Authorization": "Bearer " + sink.Token}, matrixMessage{
+		MsgType:       "m.text",
+		Body:          plain,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatted.String(),
+	})
+}
+
+func (sink *MatrixSink) idempotent() {}